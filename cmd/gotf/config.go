@@ -0,0 +1,115 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/craftypath/gotf/pkg/gotf"
+)
+
+func newConfigCommand(o *globalOpts) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the gotf configuration",
+	}
+	command.AddCommand(newConfigShowCommand(o), newConfigExplainCommand(o), newConfigValidateCommand(o), newConfigSchemaCommand())
+	return command
+}
+
+func newConfigShowCommand(o *globalOpts) *cobra.Command {
+	var (
+		output   string
+		annotate bool
+	)
+
+	command := &cobra.Command{
+		Use:   "show",
+		Short: "Print the resolved configuration for a module",
+		Long: `Print the resolved configuration for a module, i.e. the Terraform version, params,
+var files, vars, envs, and backend configs gotf would use. Sensitive values are masked.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return gotf.ShowConfig(gotf.ShowConfigArgs{
+				Args:     o.gotfArgs(nil),
+				Output:   output,
+				Annotate: annotate,
+			}, cmd.OutOrStdout())
+		},
+	}
+
+	command.Flags().StringVarP(&output, "output", "o", gotf.OutputYAML, "Output format (yaml or json)")
+	command.Flags().BoolVar(&annotate, "annotate", false, "Annotate each value with where it was defined")
+	return command
+}
+
+func newConfigExplainCommand(o *globalOpts) *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain [var...]",
+		Short: "Show where vars are defined and which definitions they override",
+		Long: `Show all definitions of the specified vars in precedence order. The effective definition
+is marked with '*'. If no vars are specified, all vars are explained.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return gotf.ExplainVars(o.gotfArgs(nil), args, cmd.OutOrStdout())
+		},
+	}
+}
+
+func newConfigValidateCommand(o *globalOpts) *cobra.Command {
+	var (
+		exhaustive bool
+		modules    []string
+	)
+
+	command := &cobra.Command{
+		Use:   "validate [config file...]",
+		Short: "Validate config files",
+		Long: `Validate config files. Besides checking keys and types, all templates must parse, referenced
+params must be defined in 'params' or 'requiredParams', and referenced var files must exist for
+each combination of allowed values of required params. If no files are specified, the file
+specified with '--config' is validated.
+
+With '--exhaustive', the config is additionally loaded for each module and each combination of
+allowed values of required params. Required params without allowed values must be specified
+using '--params'. Secrets are not resolved.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{o.cfgFile}
+			}
+			return gotf.ValidateConfigs(gotf.ValidateArgs{
+				Args:        o.gotfArgs(nil),
+				ConfigFiles: args,
+				Exhaustive:  exhaustive,
+				Modules:     modules,
+			}, cmd.OutOrStdout())
+		},
+	}
+
+	command.Flags().BoolVar(&exhaustive, "exhaustive", false, "Load the config for each module and each combination of allowed values of required params")
+	command.Flags().StringSliceVarP(&modules, "modules", "M", nil, `Module directories or glob patterns used with '--exhaustive'.
+If not specified, modules are discovered below '--module-dir'`)
+	return command
+}
+
+func newConfigSchemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema for config files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return gotf.WriteConfigSchema(cmd.OutOrStdout())
+		},
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// flagEnvPrefix is the prefix of environment variables overriding flags, e.g. 'GOTF_MODULE_DIR'.
+const flagEnvPrefix = "GOTF_"
+
+// envExcludedFlags are flags which cannot be set via environment variables. Params are set via
+// 'GOTF_PARAM_<NAME>' environment variables instead.
+var envExcludedFlags = map[string]bool{
+	"help":    true,
+	"version": true,
+	"params":  true,
+}
+
+// flagEnvName returns the name of the environment variable for the flag, e.g. 'GOTF_SKIP_BACKEND_CHECK'
+// for '--skip-backend-check'.
+func flagEnvName(flag string) string {
+	return flagEnvPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// setFlagsFromEnv sets flags of the command which were not specified on the command-line from
+// their environment variables. Flags specified on the command-line take precedence.
+func setFlagsFromEnv(command *cobra.Command) error {
+	var err error
+	command.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || envExcludedFlags[f.Name] {
+			return
+		}
+		value, ok := os.LookupEnv(flagEnvName(f.Name))
+		if !ok {
+			return
+		}
+		if setErr := command.Flags().Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for environment variable %s: %w", value, flagEnvName(f.Name), setErr)
+		}
+	})
+	return err
+}
@@ -0,0 +1,113 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetFlagsFromEnv(t *testing.T) {
+	tests := []struct {
+		name          string
+		envs          map[string]string
+		args          []string
+		wantModuleDir string
+		wantDebug     bool
+		wantErr       string
+	}{
+		{
+			name:          "defaults",
+			wantModuleDir: ".",
+		},
+		{
+			name:          "from env",
+			envs:          map[string]string{"GOTF_MODULE_DIR": "envdir", "GOTF_DEBUG": "true"},
+			wantModuleDir: "envdir",
+			wantDebug:     true,
+		},
+		{
+			name:          "cli takes precedence",
+			envs:          map[string]string{"GOTF_MODULE_DIR": "envdir", "GOTF_DEBUG": "true"},
+			args:          []string{"--module-dir", "clidir", "--debug=false"},
+			wantModuleDir: "clidir",
+		},
+		{
+			name:    "invalid value",
+			envs:    map[string]string{"GOTF_DEBUG": "maybe"},
+			wantErr: `invalid value "maybe" for environment variable GOTF_DEBUG`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.envs {
+				t.Setenv(k, v)
+			}
+			var (
+				moduleDir string
+				debug     bool
+			)
+			command := &cobra.Command{
+				Use:               "test",
+				PersistentPreRunE: func(cmd *cobra.Command, _ []string) error { return setFlagsFromEnv(cmd) },
+				RunE:              func(*cobra.Command, []string) error { return nil },
+			}
+			command.PersistentFlags().StringVarP(&moduleDir, "module-dir", "m", ".", "")
+			command.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "")
+			command.SetArgs(tt.args)
+			command.SilenceErrors = true
+			command.SilenceUsage = true
+
+			err := command.Execute()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantModuleDir, moduleDir)
+			assert.Equal(t, tt.wantDebug, debug)
+		})
+	}
+}
+
+func TestExecute_FlagsFromEnv(t *testing.T) {
+	t.Setenv("GOTF_CONFIG", "testdata/test-config.yaml")
+	t.Setenv("GOTF_MODULE_DIR", "testdata/01_networking")
+	t.Setenv("GOTF_PARAM_ENVIRONMENT", "prod")
+
+	var out bytes.Buffer
+	command := newGotfCommand()
+	command.SetArgs([]string{"config", "show"})
+	command.SetOut(&out)
+	require.NoError(t, command.Execute())
+	assert.Contains(t, out.String(), "environment: prod")
+	assert.Contains(t, out.String(), "moduleDir: 01_networking")
+}
+
+func TestHelpCommand(t *testing.T) {
+	command := newGotfCommand()
+	command.InitDefaultHelpCmd()
+	helpCmd, args, err := command.Find([]string{"help", "plan"})
+	require.NoError(t, err)
+	assert.NotEqual(t, command, helpCmd)
+	assert.Contains(t, helpCmd.Short, "Terraform's help", "help must be passed through to Terraform")
+	assert.Equal(t, []string{"plan"}, args)
+	assert.NotContains(t, command.UsageString(), "Help about any command")
+}
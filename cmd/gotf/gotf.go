@@ -15,12 +15,14 @@
 package gotf
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 
 	"github.com/spf13/cobra"
 
+	"github.com/craftypath/gotf/pkg/config"
 	"github.com/craftypath/gotf/pkg/gotf"
 	"github.com/craftypath/gotf/pkg/opts"
 )
@@ -29,8 +31,9 @@ func Execute() {
 	command := newGotfCommand()
 	if err := command.Execute(); err != nil {
 		var exitCode int
-		if err, ok := err.(*exec.ExitError); ok {
-			exitCode = err.ExitCode()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
 		} else {
 			exitCode = 1
 		}
@@ -38,13 +41,54 @@ func Execute() {
 	}
 }
 
+type globalOpts struct {
+	cfgFile          string
+	params           *opts.MapOpts
+	paramsFile       string
+	debug            bool
+	moduleDir        string
+	skipBackendCheck bool
+	noVars           bool
+	noInput          bool
+	savedPlan        bool
+}
+
+func (o *globalOpts) gotfArgs(args []string) gotf.Args {
+	return gotf.Args{
+		Debug:            o.debug,
+		ConfigFile:       o.cfgFile,
+		ModuleDir:        o.moduleDir,
+		Params:           o.params.GetAll(),
+		ParamsFile:       o.paramsFile,
+		SkipBackendCheck: o.skipBackendCheck,
+		NoVars:           o.noVars,
+		NoInput:          o.noInput,
+		SavedPlan:        o.savedPlan,
+		Args:             args,
+	}
+}
+
+// discoverConfigFile looks for the config file starting at the module directory unless it was
+// specified explicitly. If none is found, 'gotf.yaml' in the working directory is used.
+func (o *globalOpts) discoverConfigFile() error {
+	if o.cfgFile != "" {
+		return nil
+	}
+	cfgFile, err := config.Find(o.moduleDir)
+	if err != nil {
+		return fmt.Errorf("could not find config file: %w", err)
+	}
+	if cfgFile == "" {
+		cfgFile = config.DefaultFileName
+	}
+	o.cfgFile = cfgFile
+	return nil
+}
+
 func newGotfCommand() *cobra.Command {
-	var cfgFile string
-	params := opts.NewMapOpts()
-	var debug bool
-	var moduleDir string
-	var skipBackendCheck bool
-	var noVars bool
+	o := &globalOpts{
+		params: opts.NewMapOpts(),
+	}
 
 	fullVersion := fmt.Sprintf("%s (commit=%s, date=%s)", gotf.Version, gotf.GitCommit, gotf.BuildDate)
 	command := &cobra.Command{
@@ -57,30 +101,59 @@ func newGotfCommand() *cobra.Command {
  \___/ \__/ (__) (__)   %s
 
 gotf is a Terraform wrapper facilitating configurations for various environments
+
+Flags may also be set via environment variables named 'GOTF_<FLAG>', e.g. 'GOTF_MODULE_DIR'.
 `, fullVersion),
 		Version: fullVersion,
+		// Terraform args must not be interpreted as sub-commands
+		Args: cobra.ArbitraryArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if err := setFlagsFromEnv(cmd); err != nil {
+				return err
+			}
+			return o.discoverConfigFile()
+		},
 		RunE: func(_ *cobra.Command, args []string) error {
-			return gotf.Run(gotf.Args{
-				Debug:            debug,
-				ConfigFile:       cfgFile,
-				ModuleDir:        moduleDir,
-				Params:           params.GetAll(),
-				SkipBackendCheck: skipBackendCheck,
-				NoVars:           noVars,
-				Args:             args,
-			})
+			return gotf.Run(o.gotfArgs(args))
 		},
 	}
 
-	command.Flags().StringVarP(&cfgFile, "config", "c", "gotf.yaml", "Config file to be used")
-	command.Flags().VarP(params, "params", "p", "Params for templating in the config file. May be specified multiple times")
-	command.Flags().BoolVarP(&debug, "debug", "d", false, "Print additional debug output to stderr")
-	command.Flags().StringVarP(&moduleDir, "module-dir", "m", ".", "The module directory to run Terraform in")
-	command.Flags().BoolVarP(&skipBackendCheck, "skip-backend-check", "s", false, "Skip checking for changed backend configuration")
-	command.Flags().BoolVarP(&noVars, "no-vars", "n", false, `Don't add any variables when running Terraform.
-This is necessary when running 'terraform apply' with a plan file.`)
+	command.PersistentFlags().StringVarP(&o.cfgFile, "config", "c", "", `Config file to be used. If not specified, 'gotf.yaml' is searched for in the module
+directory and its parent directories up to the repository root`)
+	command.PersistentFlags().VarP(o.params, "params", "p", "Params for templating in the config file. May be specified multiple times")
+	command.PersistentFlags().StringVar(&o.paramsFile, "params-file", "", `YAML or dotenv file with params. Params specified via '--params' or
+'GOTF_PARAM_<NAME>' environment variables take precedence`)
+	command.PersistentFlags().BoolVarP(&o.debug, "debug", "d", false, "Print additional debug output to stderr")
+	command.PersistentFlags().StringVarP(&o.moduleDir, "module-dir", "m", ".", "The module directory to run Terraform in")
+	command.PersistentFlags().BoolVarP(&o.skipBackendCheck, "skip-backend-check", "s", false, "Skip checking for changed backend configuration")
+	command.PersistentFlags().BoolVarP(&o.noVars, "no-vars", "n", false, `Don't add any variables when running Terraform.
+Applying a plan file, e.g. 'apply tf.plan', is detected automatically.`)
+	command.PersistentFlags().BoolVar(&o.noInput, "no-input", false, "Don't prompt for missing required params, even if stdin is a terminal")
+	command.PersistentFlags().BoolVar(&o.savedPlan, "saved-plan", false, `Store plans under '.gotf/plans' in the module directory when running 'plan'
+and apply the matching plan without adding variables when running 'apply'`)
 	command.Flags().SetInterspersed(false)
 	command.SetVersionTemplate("{{ .Version }}\n")
+	command.CompletionOptions.DisableDefaultCmd = true
 	command.SilenceUsage = true
+
+	// 'help' is a Terraform command and must not be shadowed by cobra's help command
+	command.SetHelpCommand(newHelpCommand(o))
+	command.AddCommand(newRunAllCommand(o))
+	command.AddCommand(newConfigCommand(o))
+	return command
+}
+
+// newHelpCommand returns a command passing 'help' through to Terraform. Help for gotf is
+// available via '--help'.
+func newHelpCommand(o *globalOpts) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "help [Terraform command]",
+		Short: "Show Terraform's help (use --help for gotf's help)",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return gotf.Run(o.gotfArgs(append([]string{"help"}, args...)))
+		},
+	}
+	command.Flags().SetInterspersed(false)
 	return command
 }
@@ -0,0 +1,57 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/craftypath/gotf/pkg/gotf"
+)
+
+func newRunAllCommand(o *globalOpts) *cobra.Command {
+	var modules []string
+	var parallelism int
+	var keepGoing bool
+
+	command := &cobra.Command{
+		Use:   "run-all [flags] [Terraform args]",
+		Short: "Run Terraform in multiple modules in dependency order",
+		Long: `Run Terraform in multiple modules in dependency order.
+
+Modules are discovered recursively below the directory specified with --module-dir
+unless specified explicitly using --modules. They are ordered using 'dependsOn' from
+the config file or, if not configured, by their numeric name prefix (e.g. '01_networking').
+For 'destroy', the order is reversed.
+
+Modules which don't depend on each other may be run concurrently using --parallelism.
+Their output is then prefixed with the module name. If a module fails, no further modules
+are started unless --keep-going is specified, in which case only modules depending on the
+failed module are skipped. A summary is printed at the end.`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return gotf.RunAll(gotf.RunAllArgs{
+				Args:        o.gotfArgs(args),
+				Modules:     modules,
+				Parallelism: parallelism,
+				KeepGoing:   keepGoing,
+			})
+		},
+	}
+
+	command.Flags().StringSliceVarP(&modules, "modules", "M", nil, "Module directories or glob patterns. May be specified multiple times")
+	command.Flags().IntVar(&parallelism, "parallelism", 1, "Maximum number of modules to run concurrently")
+	command.Flags().BoolVar(&keepGoing, "keep-going", false, "Keep running modules not depending on a failed module")
+	command.Flags().SetInterspersed(false)
+	return command
+}
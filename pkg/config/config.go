@@ -16,7 +16,6 @@ package config
 
 import (
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -31,37 +30,106 @@ import (
 // All maps reresenting YAML dicts are of type map[string]interface{} so Sprig collection
 // functions can be used because they expect this type.
 type fileConfig struct {
+	Extends               stringList                        `yaml:"extends"`
+	AppendLists           bool                              `yaml:"appendLists"`
 	TerraformVersion      string                            `yaml:"terraformVersion"`
-	RequiredParams        map[string][]string               `yaml:"requiredParams"`
+	RequiredParams        map[string]ParamSpec              `yaml:"requiredParams"`
 	Params                map[string]interface{}            `yaml:"params"`
 	GlobalVarFiles        []string                          `yaml:"globalVarFiles"`
 	ModuleVarFiles        map[string][]string               `yaml:"moduleVarFiles"`
 	GlobalVars            map[string]interface{}            `yaml:"globalVars"`
 	ModuleVars            map[string]map[string]interface{} `yaml:"moduleVars"`
-	Envs                  map[string]string                 `yaml:"envs"`
+	Envs                  map[string]interface{}            `yaml:"envs"`
 	VarsFromEnvFiles      []string                          `yaml:"varsFromEnvFiles"`
+	VarsFromSopsFiles     []string                          `yaml:"varsFromSopsFiles"`
+	SensitiveVars         []string                          `yaml:"sensitiveVars"`
+	SensitiveEnvs         []string                          `yaml:"sensitiveEnvs"`
 	BackendConfigs        map[string]interface{}            `yaml:"backendConfigs"`
 	IgnoreMissingVarFiles bool                              `yaml:"ignoreMissingVarFiles"`
+	DependsOn             map[string][]string               `yaml:"dependsOn"`
+	Strict                *bool                             `yaml:"strict"`
+	VarsMode              string                            `yaml:"varsMode"`
+
+	// origins maps qualified keys, e.g. 'globalVars.foo', to their definitions in the
+	// 'extends' hierarchy, in precedence order
+	origins map[string][]fileDefinition
 }
 
 type Config struct {
 	TerraformVersion string
+	Params           map[string]interface{}
 	VarFiles         []string
 	Vars             map[string]string
 	Envs             map[string]string
 	BackendConfigs   map[string]interface{}
+	DependsOn        map[string][]string
+	// VarsMode specifies how vars are passed to Terraform, either VarsModeEnv or VarsModeFile.
+	VarsMode string
+	// JSONVars contains names of vars whose values are JSON-encoded, i.e. maps, lists, numbers,
+	// and booleans specified in the config file. All other values are plain strings.
+	JSONVars map[string]bool
+	// SensitiveValues contains values resolved from secret sources or marked as sensitive
+	// which must not be logged.
+	SensitiveValues []string
+	// SensitiveVars and SensitiveEnvs contain names of vars and envs whose values must not be logged.
+	SensitiveVars []string
+	SensitiveEnvs []string
+	// Origins records where resolved params, vars, envs, and backend configs were defined.
+	Origins Origins
 }
 
-const moduleDirParamName = "moduleDir"
+const (
+	// VarsModeEnv passes vars via 'TF_VAR_' environment variables.
+	VarsModeEnv = "env"
+	// VarsModeFile passes vars via a generated var file.
+	VarsModeFile = "file"
+)
+
+const (
+	moduleDirParamName  = "moduleDir"
+	modulePathParamName = "modulePath"
+)
 
 func Load(configFile string, modulePath string, cliParams map[string]string) (*Config, error) {
-	log.Println("Loading config file:", configFile)
-	cfgData, err := ioutil.ReadFile(configFile)
+	return loadConfig(configFile, modulePath, cliParams, true)
+}
+
+// LoadDependsOn returns the module dependencies configured in the config file and the
+// config files it extends. Unlike Load, it doesn't require params.
+func LoadDependsOn(configFile string) (map[string][]string, error) {
+	fileCfg, err := loadFile(configFile, nil)
 	if err != nil {
 		return nil, err
 	}
+	return fileCfg.DependsOn, nil
+}
+
+// ModulePath returns the path of the module directory relative to the directory of the config
+// file using forward slashes, as available via the 'modulePath' param.
+func ModulePath(configFile string, moduleDir string) (string, error) {
+	return relativeModulePath(filepath.Dir(configFile), moduleDir)
+}
 
-	fileCfg, err := load(cfgData)
+// ParamNames returns the names of the params declared under 'params' and 'requiredParams' in the
+// config file and the config files it extends.
+func ParamNames(configFile string) ([]string, error) {
+	fileCfg, err := loadFile(configFile, nil)
+	if err != nil {
+		return nil, err
+	}
+	names := sortedKeys(fileCfg.Params)
+	for _, name := range sortedKeys(fileCfg.RequiredParams) {
+		if _, ok := fileCfg.Params[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// loadConfig loads the config for the module. If resolveSecrets is false, secret sources are
+// replaced with placeholders and SOPS files are only checked for existence.
+func loadConfig(configFile string, modulePath string, cliParams map[string]string, resolveSecrets bool) (*Config, error) {
+	fileCfg, err := loadFile(configFile, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -70,13 +138,26 @@ func Load(configFile string, modulePath string, cliParams map[string]string) (*C
 		return nil, err
 	}
 
+	varsMode, err := fileCfg.varsMode()
+	if err != nil {
+		return nil, err
+	}
+
+	origins := newOrigins()
 	params := make(map[string]interface{})
 	if err := appendInterfaceParams(params, fileCfg.Params); err != nil {
 		return nil, err
 	}
+	for k := range fileCfg.Params {
+		origins.Params[k] = fileCfg.origin(SourceParams, k)
+	}
 	if err := appendStringParams(params, cliParams); err != nil {
 		return nil, err
 	}
+	for k := range cliParams {
+		origins.Params[k] = Origin{Source: SourceCLI, Key: k}
+	}
+	applyParamDefaults(fileCfg, params, origins.Params)
 
 	// we need the absolute path here in case "." was specified as relative path
 	abs, err := filepath.Abs(modulePath)
@@ -84,16 +165,31 @@ func Load(configFile string, modulePath string, cliParams map[string]string) (*C
 		return nil, err
 	}
 	params[moduleDirParamName] = filepath.Base(abs)
+	origins.Params[moduleDirParamName] = Origin{Source: SourceModuleDir}
 
-	log.Println("Processing var files...")
 	cfgFileDir := filepath.Dir(configFile)
+	relModulePath, err := relativeModulePath(cfgFileDir, modulePath)
+	if err != nil {
+		return nil, err
+	}
+	params[modulePathParamName] = relModulePath
+	origins.Params[modulePathParamName] = Origin{Source: SourceModulePath}
+
+	log.Println("Processing var files...")
 
 	cfg := &Config{
 		TerraformVersion: fileCfg.TerraformVersion,
+		Params:           params,
 		VarFiles:         []string{},
 		Vars:             make(map[string]string),
 		Envs:             make(map[string]string),
 		BackendConfigs:   make(map[string]interface{}),
+		DependsOn:        fileCfg.DependsOn,
+		VarsMode:         varsMode,
+		JSONVars:         make(map[string]bool),
+		SensitiveVars:    fileCfg.SensitiveVars,
+		SensitiveEnvs:    fileCfg.SensitiveEnvs,
+		Origins:          origins,
 	}
 
 	for _, f := range fileCfg.GlobalVarFiles {
@@ -108,52 +204,87 @@ func Load(configFile string, modulePath string, cliParams map[string]string) (*C
 
 	log.Println("Processing module var files...")
 	moduleDir := params[moduleDirParamName].(string)
-	for _, f := range fileCfg.ModuleVarFiles[moduleDir] {
-		varFilePath, err := computeModuleRelativePath(f, params, cfgFileDir, modulePath)
+	moduleVarFilesKeys, err := matchingModuleKeys(sortedKeys(fileCfg.ModuleVarFiles), moduleDir, relModulePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, moduleKey := range moduleVarFilesKeys {
+		log.Printf("Module var files key %q matches module %s\n", moduleKey, relModulePath)
+		for _, f := range fileCfg.ModuleVarFiles[moduleKey] {
+			varFilePath, err := computeModuleRelativePath(f, params, cfgFileDir, modulePath)
+			if err != nil {
+				return nil, err
+			}
+			if err := maybeAppendValFile(cfg, fileCfg.IgnoreMissingVarFiles, varFilePath, modulePath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	log.Println("Processing vars from env files...")
+	for _, f := range fileCfg.VarsFromEnvFiles {
+		path, err := computeModuleRelativePath(f, params, cfgFileDir, modulePath)
 		if err != nil {
 			return nil, err
 		}
-		if err := maybeAppendValFile(cfg, fileCfg.IgnoreMissingVarFiles, varFilePath, modulePath); err != nil {
+		if err := maybeAppendVarsFromEnvFile(cfg, fileCfg.IgnoreMissingVarFiles, path, modulePath); err != nil {
 			return nil, err
 		}
 	}
 
-	log.Println("Processing vars from env files...")
-	for _, f := range fileCfg.VarsFromEnvFiles {
+	secrets := newSecretResolver(cfgFileDir, params)
+	secrets.skip = !resolveSecrets
+
+	log.Println("Processing vars from SOPS files...")
+	for _, f := range fileCfg.VarsFromSopsFiles {
 		path, err := computeModuleRelativePath(f, params, cfgFileDir, modulePath)
 		if err != nil {
 			return nil, err
 		}
-		if err := maybeAppendVarsFromEnvFile(cfg, fileCfg.IgnoreMissingVarFiles, path, modulePath); err != nil {
+		if err := maybeAppendVarsFromSopsFile(cfg, secrets, fileCfg.IgnoreMissingVarFiles, path, modulePath); err != nil {
 			return nil, err
 		}
 	}
 
 	log.Println("Processing global vars...")
 	for key, value := range fileCfg.GlobalVars {
-		result, err := computeValue(value, params)
+		result, isJSON, err := computeVarValue(value, params, secrets)
 		if err != nil {
 			return nil, err
 		}
-		cfg.Vars[key] = result
+		cfg.setVar(key, result, fileCfg.origin(SourceGlobalVars, key), fileCfg.shadowed(SourceGlobalVars, key)...)
+		if isJSON {
+			cfg.JSONVars[key] = true
+		}
 	}
 
 	log.Println("Processing module vars...")
-	for key, value := range fileCfg.ModuleVars[moduleDir] {
-		result, err := computeValue(value, params)
-		if err != nil {
-			return nil, err
+	moduleVarsKeys, err := matchingModuleKeys(sortedKeys(fileCfg.ModuleVars), moduleDir, relModulePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, moduleKey := range moduleVarsKeys {
+		log.Printf("Module vars key %q matches module %s\n", moduleKey, relModulePath)
+		for key, value := range fileCfg.ModuleVars[moduleKey] {
+			result, isJSON, err := computeVarValue(value, params, secrets)
+			if err != nil {
+				return nil, err
+			}
+			cfg.setVar(key, result, fileCfg.origin(SourceModuleVars, moduleKey, key), fileCfg.shadowed(SourceModuleVars, moduleKey, key)...)
+			if isJSON {
+				cfg.JSONVars[key] = true
+			}
 		}
-		cfg.Vars[key] = result
 	}
 
 	log.Println("Processing envs...")
 	for key, value := range fileCfg.Envs {
-		result, err := computeValue(value, params)
+		result, err := computeValue(value, params, secrets)
 		if err != nil {
 			return nil, err
 		}
 		cfg.Envs[key] = result
+		cfg.Origins.Envs[key] = fileCfg.origin(SourceEnvs, key)
 	}
 
 	templatingInput := map[string]interface{}{
@@ -164,17 +295,15 @@ func Load(configFile string, modulePath string, cliParams map[string]string) (*C
 
 	log.Println("Processing backend configs...")
 	for key, valueTemplate := range fileCfg.BackendConfigs {
-		var result interface{}
-		if tmpl, ok := valueTemplate.(string); ok {
-			if result, err = renderTemplate(templatingInput, tmpl); err != nil {
-				return nil, err
-			}
-		} else {
-			result = valueTemplate
+		result, err := computeBackendConfig(valueTemplate, templatingInput, secrets)
+		if err != nil {
+			return nil, err
 		}
 		cfg.BackendConfigs[key] = result
+		cfg.Origins.BackendConfigs[key] = fileCfg.origin(SourceBackendConfigs, key)
 	}
 
+	cfg.SensitiveValues = secrets.sensitiveValues()
 	return cfg, nil
 }
 
@@ -222,45 +351,33 @@ func maybeAppendVarsFromEnvFile(cfg *Config, IgnoreMissingVarFiles bool, varFile
 		return err
 	}
 	for key, value := range envs {
-		cfg.Vars[strings.ToLower(key)] = value
-	}
-	return nil
-}
-
-func checkRequiredParams(fileCfg *fileConfig, cliParams map[string]string) error {
-	for k, v := range fileCfg.RequiredParams {
-		value, ok := cliParams[k]
-		if !ok {
-			return fmt.Errorf("required parameter %q must be specified", k)
-		}
-		if len(v) > 0 {
-			var hasAllowedValue bool
-			for _, allowed := range v {
-				if value == allowed {
-					hasAllowedValue = true
-					break
-				}
-			}
-			if !hasAllowedValue {
-				return fmt.Errorf("value for required parameter %q must be one of %v", k, v)
-			}
-		}
+		cfg.setVar(strings.ToLower(key), value, Origin{Source: SourceVarsFromEnvFiles, File: path, Key: key})
 	}
 	return nil
 }
 
 func load(cfgData []byte) (*fileConfig, error) {
 	var cfg fileConfig
-	if err := yaml.Unmarshal(cfgData, &cfg); err != nil {
+	err := yaml.Unmarshal(cfgData, &cfg)
+	// unknown keys and type errors are reported with line and column, unless strict mode is disabled
+	if cfg.Strict == nil || *cfg.Strict {
+		if strictErr := checkStrict(cfgData); strictErr != nil {
+			return nil, strictErr
+		}
+	}
+	if err != nil {
 		return nil, err
 	}
 	return &cfg, nil
 }
 
+func newTemplate() *template.Template {
+	return template.New("gotpl").Funcs(sprig.HermeticTxtFuncMap()).Option("missingkey=error")
+}
+
 func renderTemplate(data map[string]interface{}, tmpl string) (string, error) {
 	wr := strings.Builder{}
-	tpl := template.New("gotpl").Funcs(sprig.HermeticTxtFuncMap()).Option("missingkey=error")
-	tpl, err := tpl.Parse(tmpl)
+	tpl, err := newTemplate().Parse(tmpl)
 	if err != nil {
 		return "", err
 	}
@@ -288,19 +405,73 @@ func computeModuleRelativePath(pathTemplate string, params map[string]interface{
 	return path, nil
 }
 
-func computeValue(valueTemplate interface{}, params map[string]interface{}) (string, error) {
-	if tmpl, ok := valueTemplate.(string); ok {
+func computeValue(valueTemplate interface{}, params map[string]interface{}, secrets *secretResolver) (string, error) {
+	result, _, err := computeVarValue(valueTemplate, params, secrets)
+	return result, err
+}
+
+// computeVarValue computes the value and also reports whether the result is JSON-encoded, i.e.
+// the value is a map, list, number, or boolean.
+func computeVarValue(valueTemplate interface{}, params map[string]interface{}, secrets *secretResolver) (string, bool, error) {
+	if inner, sensitive, ok := unwrapSensitive(valueTemplate); ok {
+		result, isJSON, err := computeVarValue(inner, params, secrets)
+		if err == nil && sensitive {
+			secrets.markSensitive(result)
+		}
+		return result, isJSON, err
+	}
+	if secret, ok, err := secrets.resolve(valueTemplate); ok || err != nil {
+		return secret, false, err
+	}
+	switch v := valueTemplate.(type) {
+	case string:
 		templatingInput := map[string]interface{}{
 			"Params": params,
 		}
+		result, err := renderTemplate(templatingInput, v)
+		return result, false, err
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(v), true, nil
+	}
+	if isStructured(valueTemplate) {
+		result, err := renderStructuredValue(valueTemplate, params, secrets)
+		return result, true, err
+	}
+	return fmt.Sprint(valueTemplate), false, nil
+}
+
+// varsMode returns the configured vars mode, defaulting to VarsModeEnv.
+func (c *fileConfig) varsMode() (string, error) {
+	switch c.VarsMode {
+	case "":
+		return VarsModeEnv, nil
+	case VarsModeEnv, VarsModeFile:
+		return c.VarsMode, nil
+	default:
+		return "", fmt.Errorf("invalid varsMode %q, must be %q or %q", c.VarsMode, VarsModeEnv, VarsModeFile)
+	}
+}
+
+func computeBackendConfig(valueTemplate interface{}, templatingInput map[string]interface{}, secrets *secretResolver) (interface{}, error) {
+	if inner, sensitive, ok := unwrapSensitive(valueTemplate); ok {
+		result, err := computeBackendConfig(inner, templatingInput, secrets)
+		if err == nil && sensitive {
+			secrets.markSensitive(fmt.Sprint(result))
+		}
+		return result, err
+	}
+	if secret, ok, err := secrets.resolve(valueTemplate); ok || err != nil {
+		return secret, err
+	}
+	if tmpl, ok := valueTemplate.(string); ok {
 		return renderTemplate(templatingInput, tmpl)
 	}
-	return fmt.Sprint(valueTemplate), nil
+	return valueTemplate, nil
 }
 
 func appendStringParams(dst map[string]interface{}, src map[string]string) error {
 	for k, v := range src {
-		if err := checkForModuleDirParam(k); err != nil {
+		if err := checkForReservedParam(k); err != nil {
 			return err
 		}
 		dst[k] = v
@@ -310,7 +481,7 @@ func appendStringParams(dst map[string]interface{}, src map[string]string) error
 
 func appendInterfaceParams(dst map[string]interface{}, src map[string]interface{}) error {
 	for k, v := range src {
-		if err := checkForModuleDirParam(k); err != nil {
+		if err := checkForReservedParam(k); err != nil {
 			return err
 		}
 		dst[k] = v
@@ -318,9 +489,9 @@ func appendInterfaceParams(dst map[string]interface{}, src map[string]interface{
 	return nil
 }
 
-func checkForModuleDirParam(key string) error {
-	if key == moduleDirParamName {
-		return fmt.Errorf("param %q is reserved and set automatically", moduleDirParamName)
+func checkForReservedParam(key string) error {
+	if key == moduleDirParamName || key == modulePathParamName {
+		return fmt.Errorf("param %q is reserved and set automatically", key)
 	}
 	return nil
 }
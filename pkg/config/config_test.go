@@ -15,6 +15,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -48,6 +50,8 @@ func TestLoad(t *testing.T) {
 				},
 			},
 			want: &Config{
+				VarsMode:         VarsModeEnv,
+				JSONVars:         map[string]bool{},
 				TerraformVersion: "1.1.5",
 				VarFiles: []string{
 					"../testdata/global.tfvars",
@@ -89,6 +93,8 @@ func TestLoad(t *testing.T) {
 				},
 			},
 			want: &Config{
+				VarsMode:         VarsModeEnv,
+				JSONVars:         map[string]bool{},
 				TerraformVersion: "1.1.5",
 				VarFiles: []string{
 					"../testdata/global.tfvars",
@@ -130,6 +136,8 @@ func TestLoad(t *testing.T) {
 				},
 			},
 			want: &Config{
+				VarsMode:         VarsModeEnv,
+				JSONVars:         map[string]bool{},
 				TerraformVersion: "1.1.5",
 				VarFiles: []string{
 					"../testdata/global.tfvars",
@@ -171,6 +179,8 @@ func TestLoad(t *testing.T) {
 				},
 			},
 			want: &Config{
+				VarsMode:         VarsModeEnv,
+				JSONVars:         map[string]bool{},
 				TerraformVersion: "1.1.5",
 				VarFiles: []string{
 					"../testdata/global.tfvars",
@@ -198,6 +208,56 @@ func TestLoad(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Load config extending other configs",
+			args: struct {
+				configFile string
+				moduleDir  string
+				params     map[string]string
+			}{
+				configFile: "testdata/extends-config.yaml",
+				moduleDir:  "testmodule1",
+				params: map[string]string{
+					"environment": "dev",
+				},
+			},
+			want: &Config{
+				VarsMode:         VarsModeEnv,
+				JSONVars:         map[string]bool{"mapvar": true},
+				TerraformVersion: "1.1.5",
+				VarFiles: []string{
+					"../testdata/global.tfvars",
+					"../testdata/global-dev.tfvars",
+				},
+				Vars: map[string]string{
+					"foo":    "foovalue",
+					"bar":    "childvalue",
+					"mapvar": `{"value1":"basevalue1","value2":"childvalue2"}`,
+				},
+				Envs: map[string]string{
+					"BAR": "barvalue",
+					"BAZ": "bazvalue",
+				},
+				BackendConfigs: map[string]interface{}{
+					"key":            "testmodule1",
+					"container_name": "mytfstate-child-dev",
+				},
+				DependsOn: map[string][]string{},
+			},
+		},
+		{
+			name: "Config include cycle",
+			args: struct {
+				configFile string
+				moduleDir  string
+				params     map[string]string
+			}{
+				configFile: "testdata/extends/cycle-a.yaml",
+				moduleDir:  "testmodule1",
+			},
+			wantErr:    true,
+			wantErrMsg: `config include cycle detected: testdata/extends/cycle-a.yaml -> testdata/extends/cycle-b.yaml -> testdata/extends/cycle-a.yaml`,
+		},
 		{
 			name: "Missing required param",
 			args: struct {
@@ -244,6 +304,23 @@ func TestLoad(t *testing.T) {
 			wantErr:    true,
 			wantErrMsg: `param "moduleDir" is reserved and set automatically`,
 		},
+		{
+			name: "Module path explicitly set",
+			args: struct {
+				configFile string
+				moduleDir  string
+				params     map[string]string
+			}{
+				configFile: "testdata/test-config.yaml",
+				moduleDir:  "testmodule1",
+				params: map[string]string{
+					"environment": "dev",
+					"modulePath":  "dummy",
+				},
+			},
+			wantErr:    true,
+			wantErrMsg: `param "modulePath" is reserved and set automatically`,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -253,8 +330,219 @@ func TestLoad(t *testing.T) {
 				assert.Equal(t, tt.wantErrMsg, err.Error())
 			} else {
 				require.NoError(t, err)
+				// params and origins are covered by TestLoad_Origins
+				got.Params = nil
+				got.Origins = Origins{}
 				assert.Equal(t, tt.want, got)
 			}
 		})
 	}
 }
+
+func TestLoad_Origins(t *testing.T) {
+	got, err := Load("testdata/test-config.yaml", "testmodule1", map[string]string{"environment": "dev"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"environment": "dev",
+		"param":       "paramvalue",
+		"moduleDir":   "testmodule1",
+		"modulePath":  "../testmodule1",
+	}, got.Params)
+	assert.Equal(t, map[string]Origin{
+		"environment": {Source: SourceCLI, Key: "environment"},
+		"param":       {Source: SourceParams, File: "testdata/test-config.yaml", Key: "param"},
+		"moduleDir":   {Source: SourceModuleDir},
+		"modulePath":  {Source: SourceModulePath},
+	}, got.Origins.Params)
+	assert.Equal(t, Origin{Source: SourceGlobalVars, File: "testdata/test-config.yaml", Key: "foo"}, got.Origins.Vars["foo"])
+	assert.Equal(t, Origin{Source: SourceModuleVars, File: "testdata/test-config.yaml", Module: "testmodule1", Key: "moduleVar1"}, got.Origins.Vars["moduleVar1"])
+	assert.Equal(t, Origin{Source: SourceVarsFromEnvFiles, File: "testdata/dev.env", Key: "MY_ENV"}, got.Origins.Vars["my_env"])
+	assert.Equal(t, Origin{Source: SourceEnvs, File: "testdata/test-config.yaml", Key: "BAR"}, got.Origins.Envs["BAR"])
+	assert.Equal(t, Origin{Source: SourceBackendConfigs, File: "testdata/test-config.yaml", Key: "key", Template: "{{ .Params.moduleDir }}"}, got.Origins.BackendConfigs["key"])
+
+	extended, err := Load("testdata/extends/child.yaml", "testmodule1", map[string]string{"environment": "dev"})
+	require.NoError(t, err)
+	assert.Equal(t, Origin{Source: SourceBackendConfigs, File: "testdata/extends/base.yaml", Key: "key", Template: "{{ .Params.moduleDir }}"}, extended.Origins.BackendConfigs["key"])
+	assert.Equal(t, Origin{
+		Source:   SourceBackendConfigs,
+		File:     "testdata/extends/child.yaml",
+		Key:      "container_name",
+		Template: "mytfstate-child-{{ .Params.environment }}",
+	}, extended.Origins.BackendConfigs["container_name"])
+}
+
+func TestLoad_ShadowedVars(t *testing.T) {
+	got, err := Load("testdata/shadowing-config.yaml", "testmodule1", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "testmodule1-value", got.Vars["my_env"])
+	assert.Equal(t, Origin{
+		Source:   SourceModuleVars,
+		File:     "testdata/shadowing-config.yaml",
+		Module:   "testmodule1",
+		Key:      "my_env",
+		Template: "{{ .Params.moduleDir }}-value",
+	}, got.Origins.Vars["my_env"])
+	assert.Equal(t, []Definition{
+		{Origin: Origin{Source: SourceGlobalVars, File: "testdata/shadowing-config.yaml", Key: "my_env"}, Value: "global-value", Evaluated: true},
+		{Origin: Origin{Source: SourceVarsFromEnvFiles, File: "testdata/dev.env", Key: "MY_ENV"}, Value: "dev-env", Evaluated: true},
+	}, got.Origins.ShadowedVars["my_env"])
+
+	extended, err := Load("testdata/extends-config.yaml", "testmodule1", map[string]string{"environment": "dev"})
+	require.NoError(t, err)
+	assert.Equal(t, Origin{Source: SourceGlobalVars, File: "testdata/extends-config.yaml", Key: "foo"}, extended.Origins.Vars["foo"])
+	assert.Equal(t, []Definition{
+		{Origin: Origin{Source: SourceGlobalVars, File: "testdata/extends/base.yaml", Key: "foo"}, Value: "basevalue", Evaluated: true},
+	}, extended.Origins.ShadowedVars["foo"])
+	assert.Equal(t, []Definition{
+		{Origin: Origin{Source: SourceGlobalVars, File: "testdata/extends/base.yaml", Key: "mapvar"}},
+	}, extended.Origins.ShadowedVars["mapvar"])
+}
+
+func TestLoad_DiamondExtends(t *testing.T) {
+	got, err := Load("testdata/extends/diamond.yaml", "testmodule1", map[string]string{"environment": "dev"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"../testdata/global.tfvars",
+		"../testdata/global-dev.tfvars",
+		"../testdata/global-prod.tfvars",
+	}, got.VarFiles)
+}
+
+func TestLoadDependsOn(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "base.yaml"), []byte("dependsOn:\n  compute: [networking]\n  app: [compute]\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "gotf.yaml"), []byte("extends: [base.yaml]\nrequiredParams:\n  environment: []\ndependsOn:\n  app: [compute, database]\n"), 0644))
+
+	got, err := LoadDependsOn(filepath.Join(dir, "gotf.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"compute": {"networking"},
+		"app":     {"compute", "database"},
+	}, got)
+}
+
+func TestLoad_Secrets(t *testing.T) {
+	useFakeSops(t)
+	t.Setenv("GOTF_TEST_PASSWORD", "password-value")
+	t.Setenv("GOTF_TEST_ACCESS_KEY", "access-key-value")
+
+	got, err := Load("testdata/secrets-config.yaml", "testmodule1", map[string]string{"environment": "dev"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"plain":       "plainvalue",
+		"password":    "password-value",
+		"token":       "token-value",
+		"generated":   "dev-generated",
+		"db_password": "s3cr3t",
+		"db_config":   `{"port":5432,"user":"{{ admin }}"}`,
+		"api_key":     "api-key-value",
+	}, got.Vars)
+	assert.Equal(t, map[string]bool{"db_config": true}, got.JSONVars)
+	assert.Equal(t, map[string]string{"ARM_ACCESS_KEY": "access-key-value"}, got.Envs)
+	assert.Equal(t, map[string]interface{}{
+		"key":        "testmodule1",
+		"access_key": "access-key-value",
+	}, got.BackendConfigs)
+	assert.Equal(t, []string{
+		"access-key-value",
+		"api-key-value",
+		"dev-generated",
+		"password-value",
+		"s3cr3t",
+		"token-value",
+		`{"port":5432,"user":"{{ admin }}"}`,
+		"{{ admin }}",
+	}, got.SensitiveValues)
+}
+
+func TestLoad_SecretCommandParamsQuoted(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "gotf.yaml")
+	require.NoError(t, os.WriteFile(cfgFile, []byte(`
+globalVars:
+  plain:
+    fromCommand: echo {{ .Params.name }}
+  quoted:
+    fromCommand: echo {{ .Params.injected }}
+  conditional:
+    fromCommand: echo {{ if eq .Params.name "dev" }}yes{{ end }}
+`), 0644))
+
+	got, err := Load(cfgFile, dir, map[string]string{"name": "dev", "injected": "x; echo it's $HOME"})
+	require.NoError(t, err)
+	assert.Equal(t, "dev", got.Vars["plain"])
+	assert.Equal(t, "x; echo it's $HOME", got.Vars["quoted"])
+	assert.Equal(t, "yes", got.Vars["conditional"])
+}
+
+func TestLoad_SecretsFromExtendedConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, content string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	writeFile("common/grand.yaml", `
+globalVars:
+  grand_file:
+    fromFile: grand.txt
+  grand_command:
+    fromCommand: cat grand.txt
+`)
+	writeFile("common/grand.txt", "grand-secret")
+	writeFile("shared/base.yaml", `
+extends: ../common/grand.yaml
+globalVars:
+  file:
+    fromFile: ./secret.txt
+  command:
+    fromCommand: cat secret.txt
+  sensitive:
+    value:
+      fromFile: secret.txt
+    sensitive: true
+  tags:
+    password:
+      fromFile: secret.txt
+envs:
+  SECRET:
+    fromCommand: cat secret.txt
+`)
+	writeFile("shared/secret.txt", "parent-secret")
+	writeFile("envs/gotf.yaml", "extends: ../shared/base.yaml\n")
+	// files next to the including config file must not be used
+	writeFile("envs/secret.txt", "child-secret")
+	writeFile("envs/grand.txt", "child-secret")
+
+	got, err := Load(filepath.Join(dir, "envs", "gotf.yaml"), filepath.Join(dir, "envs"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"grand_file":    "grand-secret",
+		"grand_command": "grand-secret",
+		"file":          "parent-secret",
+		"command":       "parent-secret",
+		"sensitive":     "parent-secret",
+		"tags":          `{"password":"parent-secret"}`,
+	}, got.Vars)
+	assert.Equal(t, map[string]string{"SECRET": "parent-secret"}, got.Envs)
+}
+
+func TestLoad_MissingSecretEnv(t *testing.T) {
+	useFakeSops(t)
+	t.Setenv("GOTF_TEST_ACCESS_KEY", "access-key-value")
+	os.Unsetenv("GOTF_TEST_PASSWORD")
+
+	_, err := Load("testdata/secrets-config.yaml", "testmodule1", map[string]string{"environment": "dev"})
+	require.Error(t, err)
+	assert.Equal(t, `could not resolve secret fromEnv "GOTF_TEST_PASSWORD": environment variable "GOTF_TEST_PASSWORD" is not set`, err.Error())
+}
+
+func useFakeSops(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	t.Setenv("PATH", filepath.Join(cwd, "testdata", "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
@@ -0,0 +1,72 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DefaultFileName is the name of the config file looked for by Find.
+const DefaultFileName = "gotf.yaml"
+
+// vcsDirs are the directories marking the root of a repository.
+var vcsDirs = []string{".git", ".hg", ".svn"}
+
+// Find looks for a 'gotf.yaml' file in startDir and its parent directories up to the root of
+// the repository or the filesystem. The path of the file is returned relative to the working
+// directory if possible. If no file is found, an empty string is returned.
+func Find(startDir string) (string, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, DefaultFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return relativeToWorkingDir(path), nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if IsVCSRoot(dir) || parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// IsVCSRoot reports whether the directory is the root of a repository.
+func IsVCSRoot(dir string) bool {
+	for _, d := range vcsDirs {
+		if _, err := os.Stat(filepath.Join(dir, d)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func relativeToWorkingDir(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,93 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	mkdir := func(path ...string) string {
+		dir := filepath.Join(append([]string{root}, path...)...)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		return dir
+	}
+	touch := func(path ...string) string {
+		file := filepath.Join(append([]string{root}, path...)...)
+		require.NoError(t, os.WriteFile(file, nil, 0644))
+		return file
+	}
+
+	// outer/gotf.yaml must not be found from within repo because repo is a VCS root
+	mkdir("outer", "repo", ".git")
+	mkdir("outer", "repo", "modules", "app", "sub")
+	mkdir("outer", "repo", "nested", "modules", "db")
+	outerCfg := touch("outer", "gotf.yaml")
+	repoCfg := touch("outer", "repo", "gotf.yaml")
+	nestedCfg := touch("outer", "repo", "nested", "gotf.yaml")
+	mkdir("outer", "other", "module")
+	mkdir("outer", "repo2", ".git")
+	mkdir("outer", "repo2", "module")
+
+	tests := []struct {
+		name     string
+		startDir string
+		want     string
+	}{
+		{
+			name:     "config in start dir",
+			startDir: filepath.Join(root, "outer", "repo"),
+			want:     repoCfg,
+		},
+		{
+			name:     "config in parent dir",
+			startDir: filepath.Join(root, "outer", "repo", "modules", "app", "sub"),
+			want:     repoCfg,
+		},
+		{
+			name:     "closest config wins",
+			startDir: filepath.Join(root, "outer", "repo", "nested", "modules", "db"),
+			want:     nestedCfg,
+		},
+		{
+			name:     "no VCS root",
+			startDir: filepath.Join(root, "outer", "other", "module"),
+			want:     outerCfg,
+		},
+		{
+			name:     "stops at VCS root",
+			startDir: filepath.Join(root, "outer", "repo2", "module"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Find(tt.startDir)
+			require.NoError(t, err)
+			if tt.want == "" {
+				assert.Empty(t, got)
+				return
+			}
+			gotAbs, err := filepath.Abs(got)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, gotAbs)
+		})
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// stringList is a list of strings which may also be specified as a single string in YAML.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// loadFile loads the config file at the specified path. Config files listed under 'extends' are
+// loaded recursively and merged before the file itself is merged on top of them. The chain
+// contains the files currently being loaded and is used to detect include cycles.
+func loadFile(configFile string, chain []string) (*fileConfig, error) {
+	chain = append(chain, configFile)
+	if err := checkIncludeCycle(chain); err != nil {
+		return nil, err
+	}
+
+	log.Println("Loading config file:", configFile)
+	cfgData, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	fileCfg, err := load(cfgData)
+	if err != nil {
+		if len(chain) > 1 {
+			return nil, fmt.Errorf("%s: %w", configFile, err)
+		}
+		return nil, err
+	}
+	recordFileOrigins(fileCfg, configFile)
+	if len(fileCfg.Extends) == 0 {
+		return fileCfg, nil
+	}
+
+	cfgFileDir := filepath.Dir(configFile)
+	merged := &fileConfig{}
+	for _, parent := range fileCfg.Extends {
+		parentFile := parent
+		if !filepath.IsAbs(parentFile) {
+			parentFile = filepath.Join(cfgFileDir, parentFile)
+		}
+		parentCfg, err := loadFile(parentFile, chain)
+		if err != nil {
+			return nil, err
+		}
+		if err := rebasePaths(parentCfg, filepath.Dir(parentFile), cfgFileDir); err != nil {
+			return nil, err
+		}
+		merged = mergeFileConfigs(merged, parentCfg, fileCfg.AppendLists)
+	}
+	return mergeFileConfigs(merged, fileCfg, fileCfg.AppendLists), nil
+}
+
+func checkIncludeCycle(chain []string) error {
+	current, err := filepath.Abs(chain[len(chain)-1])
+	if err != nil {
+		return err
+	}
+	for _, f := range chain[:len(chain)-1] {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return err
+		}
+		if abs == current {
+			return fmt.Errorf("config include cycle detected: %s", strings.Join(chain, " -> "))
+		}
+	}
+	return nil
+}
+
+// rebasePaths makes the relative paths in a parent config relative to the directory
+// of the including config file because all paths are resolved relative to the latter.
+// This includes the paths of secret sources.
+func rebasePaths(cfg *fileConfig, parentDir string, cfgFileDir string) error {
+	rel, err := filepath.Rel(cfgFileDir, parentDir)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+
+	rebase := func(paths []string) []string {
+		result := make([]string, 0, len(paths))
+		for _, p := range paths {
+			if !filepath.IsAbs(p) {
+				// no filepath.Join here because it would clean paths inside of templates
+				p = filepath.ToSlash(rel) + "/" + p
+			}
+			result = append(result, p)
+		}
+		return result
+	}
+
+	cfg.GlobalVarFiles = rebase(cfg.GlobalVarFiles)
+	cfg.VarsFromEnvFiles = rebase(cfg.VarsFromEnvFiles)
+	cfg.VarsFromSopsFiles = rebase(cfg.VarsFromSopsFiles)
+	for k, v := range cfg.ModuleVarFiles {
+		cfg.ModuleVarFiles[k] = rebase(v)
+	}
+
+	rebaseValues := func(values map[string]interface{}) {
+		for k, v := range values {
+			values[k] = rebaseSecretSources(v, filepath.ToSlash(rel))
+		}
+	}
+	rebaseValues(cfg.GlobalVars)
+	for _, vars := range cfg.ModuleVars {
+		rebaseValues(vars)
+	}
+	rebaseValues(cfg.Envs)
+	rebaseValues(cfg.BackendConfigs)
+	return nil
+}
+
+// mergeFileConfigs merges override on top of base. Maps are merged deeply with values from
+// override taking precedence. Lists from override replace those from base unless appendLists
+// is set, in which case they are appended. It is set by the including config file, so it also
+// applies when merging its parent config files.
+func mergeFileConfigs(base *fileConfig, override *fileConfig, appendLists bool) *fileConfig {
+	mergeLists := func(dst []string, src []string) []string {
+		if appendLists {
+			return appendPaths(dst, src)
+		}
+		if src != nil {
+			return src
+		}
+		return dst
+	}
+
+	result := &fileConfig{
+		TerraformVersion:      base.TerraformVersion,
+		VarsMode:              base.VarsMode,
+		RequiredParams:        make(map[string]ParamSpec),
+		Params:                mergeMaps(base.Params, override.Params),
+		GlobalVarFiles:        mergeLists(base.GlobalVarFiles, override.GlobalVarFiles),
+		ModuleVarFiles:        make(map[string][]string),
+		GlobalVars:            mergeMaps(base.GlobalVars, override.GlobalVars),
+		ModuleVars:            make(map[string]map[string]interface{}),
+		Envs:                  mergeMaps(base.Envs, override.Envs),
+		VarsFromEnvFiles:      mergeLists(base.VarsFromEnvFiles, override.VarsFromEnvFiles),
+		VarsFromSopsFiles:     mergeLists(base.VarsFromSopsFiles, override.VarsFromSopsFiles),
+		SensitiveVars:         concatLists(base.SensitiveVars, override.SensitiveVars),
+		SensitiveEnvs:         concatLists(base.SensitiveEnvs, override.SensitiveEnvs),
+		BackendConfigs:        mergeMaps(base.BackendConfigs, override.BackendConfigs),
+		IgnoreMissingVarFiles: base.IgnoreMissingVarFiles || override.IgnoreMissingVarFiles,
+		DependsOn:             make(map[string][]string),
+		origins:               mergeOrigins(base.origins, override.origins),
+	}
+	if override.TerraformVersion != "" {
+		result.TerraformVersion = override.TerraformVersion
+	}
+	if override.VarsMode != "" {
+		result.VarsMode = override.VarsMode
+	}
+	for k, v := range base.RequiredParams {
+		result.RequiredParams[k] = v
+	}
+	for k, v := range override.RequiredParams {
+		result.RequiredParams[k] = v
+	}
+	for k, v := range base.ModuleVarFiles {
+		result.ModuleVarFiles[k] = v
+	}
+	for k, v := range override.ModuleVarFiles {
+		result.ModuleVarFiles[k] = mergeLists(result.ModuleVarFiles[k], v)
+	}
+	for k, v := range base.ModuleVars {
+		result.ModuleVars[k] = v
+	}
+	for k, v := range override.ModuleVars {
+		result.ModuleVars[k] = mergeMaps(result.ModuleVars[k], v)
+	}
+	for k, v := range base.DependsOn {
+		result.DependsOn[k] = v
+	}
+	for k, v := range override.DependsOn {
+		result.DependsOn[k] = v
+	}
+	return result
+}
+
+// appendPaths appends paths which are not yet contained in dst. If parents in the 'extends'
+// hierarchy share a common base, its paths would otherwise be included repeatedly.
+func appendPaths(dst []string, src []string) []string {
+	result := make([]string, 0, len(dst)+len(src))
+	seen := make(map[string]bool, len(dst)+len(src))
+	for _, p := range append(append([]string{}, dst...), src...) {
+		// paths are rebased by prefixing them, e.g. 'parent/../base.tfvars', so they are compared cleaned
+		key := path.Clean(p)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, p)
+	}
+	return result
+}
+
+// concatLists is used for lists which are always accumulated, e.g. names of sensitive vars.
+func concatLists(base []string, override []string) []string {
+	if len(base)+len(override) == 0 {
+		return nil
+	}
+	return append(append([]string{}, base...), override...)
+}
+
+func mergeMaps(base map[string]interface{}, override map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range override {
+		result[k] = mergeValues(result[k], v)
+	}
+	return result
+}
+
+// mergeValues deeply merges nested YAML maps. Any other values are replaced.
+func mergeValues(base interface{}, override interface{}) interface{} {
+	baseMap, ok := base.(map[interface{}]interface{})
+	if !ok {
+		return override
+	}
+	overrideMap, ok := override.(map[interface{}]interface{})
+	if !ok {
+		return override
+	}
+	result := make(map[interface{}]interface{}, len(baseMap)+len(overrideMap))
+	for k, v := range baseMap {
+		result[k] = v
+	}
+	for k, v := range overrideMap {
+		result[k] = mergeValues(result[k], v)
+	}
+	return result
+}
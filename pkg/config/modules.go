@@ -0,0 +1,185 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// regexKeyPrefix marks keys of 'moduleVars' and 'moduleVarFiles' which are regular expressions.
+const regexKeyPrefix = "~"
+
+type moduleKeyKind int
+
+// Kinds of module keys in ascending order of specificity.
+const (
+	moduleKeyRegex moduleKeyKind = iota
+	moduleKeyGlob
+	moduleKeyExact
+	moduleKeyPath
+)
+
+// moduleKey is a key of 'moduleVars' or 'moduleVarFiles' selecting the modules the entry
+// applies to. Keys are module directory names, module paths, glob patterns, or regular
+// expressions prefixed with '~'. Keys containing a slash and regular expressions are matched
+// against the module path relative to the config file, other keys against the module
+// directory name.
+type moduleKey struct {
+	key  string
+	kind moduleKeyKind
+	// literals is the number of non-wildcard characters of glob patterns
+	literals int
+	re       *regexp.Regexp
+}
+
+func parseModuleKey(key string) (moduleKey, error) {
+	if strings.HasPrefix(key, regexKeyPrefix) {
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(key, regexKeyPrefix) + ")$")
+		if err != nil {
+			return moduleKey{}, fmt.Errorf("invalid regular expression in module key %q: %w", key, err)
+		}
+		return moduleKey{key: key, kind: moduleKeyRegex, re: re}, nil
+	}
+	if !strings.ContainsAny(key, "*?[") {
+		if strings.Contains(key, "/") {
+			return moduleKey{key: key, kind: moduleKeyPath, literals: len(key)}, nil
+		}
+		return moduleKey{key: key, kind: moduleKeyExact, literals: len(key)}, nil
+	}
+	re, literals, err := globToRegex(key)
+	if err != nil {
+		return moduleKey{}, fmt.Errorf("invalid glob pattern in module key %q: %w", key, err)
+	}
+	return moduleKey{key: key, kind: moduleKeyGlob, literals: literals, re: re}, nil
+}
+
+func (k moduleKey) matches(moduleDir string, modulePath string) bool {
+	switch k.kind {
+	case moduleKeyRegex:
+		return k.re.MatchString(modulePath)
+	case moduleKeyGlob:
+		if strings.Contains(k.key, "/") {
+			return k.re.MatchString(modulePath)
+		}
+		return k.re.MatchString(moduleDir)
+	case moduleKeyPath:
+		return path.Clean(k.key) == modulePath
+	default:
+		return k.key == moduleDir
+	}
+}
+
+// lessSpecific reports whether k is less specific than other. Regular expressions are least
+// specific, followed by glob patterns, module directory names, and module paths. Glob patterns
+// with fewer literal characters are less specific.
+func (k moduleKey) lessSpecific(other moduleKey) bool {
+	if k.kind != other.kind {
+		return k.kind < other.kind
+	}
+	if k.literals != other.literals {
+		return k.literals < other.literals
+	}
+	return k.key < other.key
+}
+
+// matchingModuleKeys returns the keys matching the module ordered from least to most specific,
+// so entries of later keys take precedence.
+func matchingModuleKeys(keys []string, moduleDir string, modulePath string) ([]string, error) {
+	var matching []moduleKey
+	for _, key := range keys {
+		k, err := parseModuleKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if k.matches(moduleDir, modulePath) {
+			matching = append(matching, k)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].lessSpecific(matching[j])
+	})
+	result := make([]string, len(matching))
+	for i, k := range matching {
+		result[i] = k.key
+	}
+	return result, nil
+}
+
+// globToRegex converts a glob pattern to a regular expression. '*' and '?' don't match '/',
+// '**' matches across directories. It also returns the number of literal characters.
+func globToRegex(pattern string) (*regexp.Regexp, int, error) {
+	var sb strings.Builder
+	var literals int
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				// '**/' also matches no directory at all
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, 0, fmt.Errorf("missing closing ']'")
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			literals++
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	return re, literals, err
+}
+
+// relativeModulePath returns the module path relative to the config file directory using
+// forward slashes.
+func relativeModulePath(cfgFileDir string, modulePath string) (string, error) {
+	absCfgFileDir, err := filepath.Abs(cfgFileDir)
+	if err != nil {
+		return "", err
+	}
+	absModulePath, err := filepath.Abs(modulePath)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absCfgFileDir, absModulePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
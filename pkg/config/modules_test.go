@@ -0,0 +1,193 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchingModuleKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []string
+		moduleDir  string
+		modulePath string
+		want       []string
+		wantErr    string
+	}{
+		{
+			name:       "exact",
+			keys:       []string{"svc_a", "svc_b"},
+			moduleDir:  "svc_a",
+			modulePath: "services/svc_a",
+			want:       []string{"svc_a"},
+		},
+		{
+			name:       "glob without slash matches directory name",
+			keys:       []string{"svc_*", "svc_?", "services"},
+			moduleDir:  "svc_a",
+			modulePath: "services/svc_a",
+			want:       []string{"svc_*", "svc_?"},
+		},
+		{
+			name:       "glob with slash matches relative path",
+			keys:       []string{"services/*", "*/svc_a", "svc/*", "services/*/nested"},
+			moduleDir:  "svc_a",
+			modulePath: "services/svc_a",
+			want:       []string{"*/svc_a", "services/*"},
+		},
+		{
+			name:       "double star",
+			keys:       []string{"**/db", "platform/**", "*/db"},
+			moduleDir:  "db",
+			modulePath: "platform/data/db",
+			want:       []string{"**/db", "platform/**"},
+		},
+		{
+			name:       "double star matches no directory",
+			keys:       []string{"**/db"},
+			moduleDir:  "db",
+			modulePath: "db",
+			want:       []string{"**/db"},
+		},
+		{
+			name:       "character class",
+			keys:       []string{"svc_[ab]", "svc_[!a]"},
+			moduleDir:  "svc_a",
+			modulePath: "services/svc_a",
+			want:       []string{"svc_[ab]"},
+		},
+		{
+			name:       "regex matches relative path completely",
+			keys:       []string{"~services/svc_[a-z]+", "~svc_a", "~.*"},
+			moduleDir:  "svc_a",
+			modulePath: "services/svc_a",
+			want:       []string{"~.*", "~services/svc_[a-z]+"},
+		},
+		{
+			name:       "least to most specific",
+			keys:       []string{"svc_a", "services/svc_*", "~services/.*", "*", "services/*"},
+			moduleDir:  "svc_a",
+			modulePath: "services/svc_a",
+			want:       []string{"~services/.*", "*", "services/*", "services/svc_*", "svc_a"},
+		},
+		{
+			name:       "path",
+			keys:       []string{"aws/network", "azure/network", "network"},
+			moduleDir:  "network",
+			modulePath: "aws/network",
+			want:       []string{"network", "aws/network"},
+		},
+		{
+			name:       "path is cleaned",
+			keys:       []string{"./aws/network/", "aws/network/../network"},
+			moduleDir:  "network",
+			modulePath: "aws/network",
+			want:       []string{"./aws/network/", "aws/network/../network"},
+		},
+		{
+			name:       "path outside config dir",
+			keys:       []string{"../shared/network", "network"},
+			moduleDir:  "network",
+			modulePath: "../shared/network",
+			want:       []string{"network", "../shared/network"},
+		},
+		{
+			name:    "invalid regex",
+			keys:    []string{"~svc_("},
+			wantErr: `invalid regular expression in module key "~svc_("`,
+		},
+		{
+			name:    "invalid glob",
+			keys:    []string{"svc_[a"},
+			wantErr: `invalid glob pattern in module key "svc_[a": missing closing ']'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchingModuleKeys(tt.keys, tt.moduleDir, tt.modulePath)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoad_ModulePatterns(t *testing.T) {
+	tests := []struct {
+		modulePath   string
+		wantVarFiles []string
+		wantVars     map[string]string
+	}{
+		{
+			modulePath:   "testdata/modules/services/svc_a",
+			wantVarFiles: []string{"../../svc.tfvars", "../../services.tfvars", "../../svc_a.tfvars"},
+			wantVars:     map[string]string{"tier": "backend", "replicas": "3", "owner": "platform"},
+		},
+		{
+			modulePath:   "testdata/modules/services/svc_b",
+			wantVarFiles: []string{"../../svc.tfvars", "../../services.tfvars"},
+			wantVars:     map[string]string{"tier": "backend", "replicas": "2", "owner": "platform"},
+		},
+		{
+			modulePath:   "testdata/modules/services/db",
+			wantVarFiles: []string{"../../services.tfvars"},
+			wantVars:     map[string]string{"tier": "data", "replicas": "1", "owner": "platform"},
+		},
+		{
+			modulePath:   "testdata/modules/aws/network",
+			wantVarFiles: []string{},
+			wantVars:     map[string]string{"name": "aws/network", "cloud": "aws"},
+		},
+		{
+			modulePath:   "testdata/modules/azure/network",
+			wantVarFiles: []string{},
+			wantVars:     map[string]string{"name": "azure/network", "cloud": "azure"},
+		},
+		{
+			modulePath:   "testdata/modules/gcp/network",
+			wantVarFiles: []string{},
+			wantVars:     map[string]string{"name": "gcp/network", "cloud": "unknown"},
+		},
+		{
+			modulePath:   "testdata/modules/frontend",
+			wantVarFiles: []string{},
+			wantVars:     map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.modulePath, func(t *testing.T) {
+			got, err := Load("testdata/modules/gotf.yaml", tt.modulePath, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVarFiles, got.VarFiles)
+			assert.Equal(t, tt.wantVars, got.Vars)
+		})
+	}
+
+	got, err := Load("testdata/modules/gotf.yaml", "testdata/modules/services/svc_a", nil)
+	require.NoError(t, err)
+	assert.Equal(t, Origin{Source: SourceModuleVars, File: "testdata/modules/gotf.yaml", Module: "svc_a", Key: "replicas"}, got.Origins.Vars["replicas"])
+	assert.Equal(t, []Definition{
+		{Origin: Origin{Source: SourceModuleVars, File: "testdata/modules/gotf.yaml", Module: "services/svc_*", Key: "replicas"}, Value: "2", Evaluated: true},
+		{Origin: Origin{Source: SourceModuleVars, File: "testdata/modules/gotf.yaml", Module: "~services/.*", Key: "replicas"}, Value: "1", Evaluated: true},
+	}, got.Origins.ShadowedVars["replicas"])
+}
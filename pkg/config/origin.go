@@ -0,0 +1,226 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Sources of config values.
+const (
+	SourceParams            = "params"
+	SourceCLI               = "cli"
+	SourceEnvironment       = "environment"
+	SourceParamsFile        = "paramsFile"
+	SourceRequiredParams    = "requiredParams"
+	SourceModuleDir         = "moduleDir"
+	SourceModulePath        = "modulePath"
+	SourceGlobalVars        = "globalVars"
+	SourceModuleVars        = "moduleVars"
+	SourceVarsFromEnvFiles  = "varsFromEnvFiles"
+	SourceVarsFromSopsFiles = "varsFromSopsFiles"
+	SourceEnvs              = "envs"
+	SourceBackendConfigs    = "backendConfigs"
+	// SourceVarFiles and SourceAutoVarFiles are var files passed to Terraform and var files
+	// loaded automatically by Terraform, respectively.
+	SourceVarFiles     = "varFiles"
+	SourceAutoVarFiles = "autoVarFiles"
+)
+
+// Origin describes where a config value was defined.
+type Origin struct {
+	// Source is the config section or mechanism the value came from, e.g. 'globalVars'.
+	Source string `json:"source" yaml:"source"`
+	// File is the config file or env file the value was defined in.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Module is the key of the 'moduleVars' entry the value was defined in, which may be a pattern.
+	Module string `json:"module,omitempty" yaml:"module,omitempty"`
+	// Key is the key in the file.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Template is the unrendered value if the value is a template.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+}
+
+func (o Origin) String() string {
+	s := o.Source
+	if o.File != "" {
+		s += fmt.Sprintf(" (%s", o.File)
+		if o.Module != "" {
+			s += fmt.Sprintf(", module %s", o.Module)
+		}
+		if o.Key != "" {
+			s += fmt.Sprintf(", key %s", o.Key)
+		}
+		s += ")"
+	} else if o.Key != "" && o.Source == SourceEnvironment {
+		s += fmt.Sprintf(" (%s)", o.Key)
+	}
+	return s
+}
+
+// Definition is a shadowed definition of a value.
+type Definition struct {
+	Origin `yaml:",inline"`
+	// Value is the resolved value. Definitions shadowed by another config file in the 'extends'
+	// hierarchy are not evaluated, so only plain string values are available for them.
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	// Evaluated reports whether Value is available.
+	Evaluated bool `json:"-" yaml:"-"`
+}
+
+// Origins maps names of resolved values to their origins.
+type Origins struct {
+	Params         map[string]Origin
+	Vars           map[string]Origin
+	Envs           map[string]Origin
+	BackendConfigs map[string]Origin
+	// ShadowedVars lists definitions of vars which were overridden, in precedence order.
+	ShadowedVars map[string][]Definition
+}
+
+func newOrigins() Origins {
+	return Origins{
+		Params:         make(map[string]Origin),
+		Vars:           make(map[string]Origin),
+		Envs:           make(map[string]Origin),
+		BackendConfigs: make(map[string]Origin),
+		ShadowedVars:   make(map[string][]Definition),
+	}
+}
+
+// fileDefinition is the raw value of a key in a config file.
+type fileDefinition struct {
+	file  string
+	value interface{}
+}
+
+// recordFileOrigins records configFile as origin for all keys defined in cfg.
+// Keys are qualified with their section, e.g. 'globalVars.foo'.
+func recordFileOrigins(cfg *fileConfig, configFile string) {
+	cfg.origins = make(map[string][]fileDefinition)
+	record := func(value interface{}, source string, path ...string) {
+		cfg.origins[qualify(source, path...)] = []fileDefinition{{file: configFile, value: value}}
+	}
+	for k, v := range cfg.Params {
+		record(v, SourceParams, k)
+	}
+	for k, v := range cfg.RequiredParams {
+		if v.Default != nil {
+			record(*v.Default, SourceRequiredParams, k)
+		}
+	}
+	for k, v := range cfg.GlobalVars {
+		record(v, SourceGlobalVars, k)
+	}
+	for module, vars := range cfg.ModuleVars {
+		for k, v := range vars {
+			record(v, SourceModuleVars, module, k)
+		}
+	}
+	for k, v := range cfg.Envs {
+		record(v, SourceEnvs, k)
+	}
+	for k, v := range cfg.BackendConfigs {
+		record(v, SourceBackendConfigs, k)
+	}
+}
+
+// mergeOrigins merges the origins of an including config file with those of the file it
+// extends. Definitions are kept in precedence order.
+func mergeOrigins(base map[string][]fileDefinition, override map[string][]fileDefinition) map[string][]fileDefinition {
+	result := make(map[string][]fileDefinition, len(base)+len(override))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range override {
+		result[k] = append(append([]fileDefinition{}, v...), result[k]...)
+	}
+	return result
+}
+
+// origin returns the origin of the effective definition of the key.
+func (c *fileConfig) origin(source string, path ...string) Origin {
+	origin := Origin{Source: source, Module: modulePathKey(source, path), Key: path[len(path)-1]}
+	if defs := c.origins[qualify(source, path...)]; len(defs) > 0 {
+		origin.File = defs[0].file
+		if s, ok := defs[0].value.(string); ok && isTemplate(s) {
+			origin.Template = s
+		}
+	}
+	return origin
+}
+
+// shadowed returns the definitions of the key overridden within the 'extends' hierarchy.
+func (c *fileConfig) shadowed(source string, path ...string) []Definition {
+	defs := c.origins[qualify(source, path...)]
+	if len(defs) < 2 {
+		return nil
+	}
+	result := make([]Definition, 0, len(defs)-1)
+	for _, d := range defs[1:] {
+		def := Definition{Origin: Origin{Source: source, File: d.file, Module: modulePathKey(source, path), Key: path[len(path)-1]}}
+		if s, ok := d.value.(string); ok {
+			if isTemplate(s) {
+				def.Template = s
+			} else {
+				def.Value = s
+				def.Evaluated = true
+			}
+		}
+		result = append(result, def)
+	}
+	return result
+}
+
+// setVar sets the var to a string value and records its origin. If the var is already set, the
+// previous definition is recorded as shadowed.
+func (c *Config) setVar(name string, value string, origin Origin, shadowed ...Definition) {
+	for _, d := range shadowed {
+		log.Printf("Var %s from %s shadows definition from %s\n", name, origin, d.Origin)
+	}
+	if prev, ok := c.Origins.Vars[name]; ok {
+		log.Printf("Var %s from %s shadows definition from %s\n", name, origin, prev)
+		shadowed = append(shadowed, Definition{Origin: prev, Value: c.Vars[name], Evaluated: true})
+		shadowed = append(shadowed, c.Origins.ShadowedVars[name]...)
+	}
+	c.Vars[name] = value
+	delete(c.JSONVars, name)
+	c.Origins.Vars[name] = origin
+	if len(shadowed) > 0 {
+		c.Origins.ShadowedVars[name] = shadowed
+	}
+}
+
+// modulePathKey returns the module key of 'moduleVars' definitions.
+func modulePathKey(source string, path []string) string {
+	if source == SourceModuleVars && len(path) > 1 {
+		return path[0]
+	}
+	return ""
+}
+
+func isTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+func qualify(source string, path ...string) string {
+	key := source
+	for _, p := range path {
+		key += "." + p
+	}
+	return key
+}
@@ -0,0 +1,182 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ParamSpec describes a param listed under 'requiredParams'. It may be specified as list of
+// allowed values or as map with the fields below.
+type ParamSpec struct {
+	Description string `yaml:"description"`
+	// Values are the allowed values. If empty, any value is allowed.
+	Values []string `yaml:"values"`
+	// Pattern is a regular expression the value must match completely.
+	Pattern string `yaml:"pattern"`
+	// Default makes the param optional. It is used if the param is not specified.
+	Default *string `yaml:"default"`
+}
+
+func (s *ParamSpec) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var values []string
+	if err := unmarshal(&values); err == nil {
+		*s = ParamSpec{Values: values}
+		return nil
+	}
+	// avoid recursion
+	type paramSpec ParamSpec
+	var spec paramSpec
+	if err := unmarshal(&spec); err != nil {
+		return err
+	}
+	*s = ParamSpec(spec)
+	return nil
+}
+
+// Optional reports whether the param has a default value.
+func (s ParamSpec) Optional() bool {
+	return s.Default != nil
+}
+
+// Check returns an error if the value is not allowed.
+func (s ParamSpec) Check(value string) error {
+	if len(s.Values) > 0 {
+		var allowed bool
+		for _, v := range s.Values {
+			if v == value {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("must be one of %v", s.Values)
+		}
+	}
+	if s.Pattern != "" {
+		re, err := s.compilePattern()
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("must match pattern %q", s.Pattern)
+		}
+	}
+	return nil
+}
+
+func (s ParamSpec) compilePattern() (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + s.Pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+	}
+	return re, nil
+}
+
+func (s ParamSpec) describe(msg string) string {
+	if s.Description != "" {
+		return fmt.Sprintf("%s (%s)", msg, s.Description)
+	}
+	return msg
+}
+
+// MissingParam is a required param which was not specified.
+type MissingParam struct {
+	Name string
+	ParamSpec
+}
+
+// ParamsError lists all violations of required params.
+type ParamsError struct {
+	Violations []string
+	// Missing are the required params which were not specified.
+	Missing []MissingParam
+}
+
+func (e *ParamsError) Error() string {
+	if len(e.Violations) == 1 {
+		return e.Violations[0]
+	}
+	return fmt.Sprintf("%d parameter violations:\n  %s", len(e.Violations), strings.Join(e.Violations, "\n  "))
+}
+
+// checkRequiredParams checks the specified params against the 'requiredParams' of the config
+// file and returns a *ParamsError listing all violations.
+func checkRequiredParams(fileCfg *fileConfig, cliParams map[string]string) error {
+	paramsErr := &ParamsError{}
+	for _, name := range sortedKeys(fileCfg.RequiredParams) {
+		spec := fileCfg.RequiredParams[name]
+		value, ok := cliParams[name]
+		if !ok {
+			if spec.Optional() {
+				value = *spec.Default
+			} else {
+				paramsErr.Missing = append(paramsErr.Missing, MissingParam{Name: name, ParamSpec: spec})
+				paramsErr.Violations = append(paramsErr.Violations, spec.describe(fmt.Sprintf("required parameter %q must be specified", name)))
+				continue
+			}
+		}
+		if err := spec.Check(value); err != nil {
+			if ok {
+				paramsErr.Violations = append(paramsErr.Violations, spec.describe(fmt.Sprintf("value for required parameter %q %v", name, err)))
+			} else {
+				paramsErr.Violations = append(paramsErr.Violations, spec.describe(fmt.Sprintf("default value for required parameter %q %v", name, err)))
+			}
+		}
+	}
+	if len(paramsErr.Violations) > 0 {
+		return paramsErr
+	}
+	return nil
+}
+
+// applyParamDefaults sets default values of required params which were not specified.
+func applyParamDefaults(fileCfg *fileConfig, params map[string]interface{}, origins map[string]Origin) {
+	for name, spec := range fileCfg.RequiredParams {
+		if _, ok := params[name]; ok || !spec.Optional() {
+			continue
+		}
+		params[name] = *spec.Default
+		origins[name] = fileCfg.origin(SourceRequiredParams, name)
+	}
+}
+
+// RunParams returns the params specified for the run, e.g. via the command-line or defaults of
+// required params. Params from the config file and the automatically set module params are
+// omitted since they are the same for every run of a module.
+func (c *Config) RunParams() map[string]string {
+	params := make(map[string]string)
+	for name, value := range c.Params {
+		switch c.Origins.Params[name].Source {
+		case SourceParams, SourceModuleDir, SourceModulePath:
+			continue
+		}
+		params[name] = fmt.Sprint(value)
+	}
+	return params
+}
+
+// DescribeParams describes params sorted by name, e.g. 'environment=dev, region=eu'.
+func DescribeParams(params map[string]string) string {
+	parts := make([]string, 0, len(params))
+	for name, value := range params {
+		parts = append(parts, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,106 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_RequiredParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      map[string]string
+		wantVars    map[string]string
+		wantErrMsg  string
+		wantMissing []string
+	}{
+		{
+			name:     "default value",
+			params:   map[string]string{"environment": "feature-login-page", "team": "data"},
+			wantVars: map[string]string{"environment": "feature-login-page", "region": "westeurope", "team": "data"},
+		},
+		{
+			name:     "overridden default value",
+			params:   map[string]string{"environment": "prod", "region": "northeurope", "team": "platform"},
+			wantVars: map[string]string{"environment": "prod", "region": "northeurope", "team": "platform"},
+		},
+		{
+			name:   "all violations",
+			params: map[string]string{"environment": "feature_x", "region": "eastus"},
+			wantErrMsg: `3 parameter violations:
+  value for required parameter "environment" must match pattern "dev|prod|feature-[a-z0-9-]+" (Target environment)
+  value for required parameter "region" must be one of [westeurope northeurope] (Azure region)
+  required parameter "team" must be specified`,
+			wantMissing: []string{"team"},
+		},
+		{
+			name:        "single violation",
+			params:      map[string]string{"team": "data"},
+			wantErrMsg:  `required parameter "environment" must be specified (Target environment)`,
+			wantMissing: []string{"environment"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load("testdata/params-config.yaml", "testmodule1", tt.params)
+			if tt.wantErrMsg != "" {
+				var paramsErr *ParamsError
+				require.ErrorAs(t, err, &paramsErr)
+				assert.Equal(t, tt.wantErrMsg, err.Error())
+				var missing []string
+				for _, p := range paramsErr.Missing {
+					missing = append(missing, p.Name)
+				}
+				assert.Equal(t, tt.wantMissing, missing)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVars, got.Vars)
+		})
+	}
+}
+
+func TestLoad_RequiredParamsDefaultOrigin(t *testing.T) {
+	got, err := Load("testdata/params-config.yaml", "testmodule1", map[string]string{"environment": "dev", "team": "data"})
+	require.NoError(t, err)
+	assert.Equal(t, Origin{Source: SourceRequiredParams, File: "testdata/params-config.yaml", Key: "region"}, got.Origins.Params["region"])
+}
+
+func TestLoad_RequiredParamsUnknownKey(t *testing.T) {
+	_, err := Load("testdata/strict/params.yaml", "testmodule1", nil)
+	require.Error(t, err)
+	assert.Equal(t, `invalid config:
+  line 3, column 5: unknown key "descripton" in requiredParams.environment, did you mean "description"?`, err.Error())
+}
+
+func TestConfig_RunParams(t *testing.T) {
+	cfg := &Config{
+		Params: map[string]interface{}{"environment": "dev", "replicas": 3, "team": "a", "moduleDir": "app", "modulePath": "aws/app"},
+		Origins: Origins{Params: map[string]Origin{
+			"environment": {Source: SourceCLI},
+			"replicas":    {Source: SourceRequiredParams},
+			"team":        {Source: SourceParams},
+			"moduleDir":   {Source: SourceModuleDir},
+			"modulePath":  {Source: SourceModulePath},
+		}},
+	}
+	got := cfg.RunParams()
+	assert.Equal(t, map[string]string{"environment": "dev", "replicas": "3"}, got)
+	assert.Equal(t, "environment=dev, replicas=3", DescribeParams(got))
+	assert.Empty(t, DescribeParams(nil))
+}
@@ -0,0 +1,379 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	fromCommandKey = "fromCommand"
+	fromFileKey    = "fromFile"
+	fromEnvKey     = "fromEnv"
+)
+
+// shellSafeRegex matches values which can be passed to the shell without quoting.
+var shellSafeRegex = regexp.MustCompile(`^[A-Za-z0-9_./:@+=,-]*$`)
+
+// sensitiveNamePatterns are used to detect sensitive vars, envs, and backend configs by name.
+var sensitiveNamePatterns = []string{"*password*", "*secret*", "*token*", "*key*"}
+
+// IsSensitiveVar reports whether the var is listed under 'sensitiveVars' or its name looks sensitive.
+func (c *Config) IsSensitiveVar(name string) bool {
+	return isSensitiveName(name, c.SensitiveVars)
+}
+
+// IsSensitiveEnv reports whether the env is listed under 'sensitiveEnvs' or its name looks sensitive.
+func (c *Config) IsSensitiveEnv(name string) bool {
+	return isSensitiveName(name, c.SensitiveEnvs)
+}
+
+// IsSensitiveBackendConfig reports whether the backend config's name looks sensitive.
+// 'key' is the state file path for most backends and thus not considered sensitive.
+func (c *Config) IsSensitiveBackendConfig(name string) bool {
+	return name != "key" && isSensitiveName(name, nil)
+}
+
+func isSensitiveName(name string, sensitiveNames []string) bool {
+	for _, n := range sensitiveNames {
+		if n == name {
+			return true
+		}
+	}
+	lower := strings.ToLower(name)
+	for _, p := range sensitiveNamePatterns {
+		if matched, _ := path.Match(p, lower); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// secretResolver resolves values from external sources, e.g. '{ fromCommand: "pass show foo" }'.
+// All resolved values are recorded as sensitive.
+type secretResolver struct {
+	cfgFileDir string
+	params     map[string]interface{}
+	sensitive  map[string]bool
+	// skip replaces secrets with placeholders instead of resolving them
+	skip bool
+}
+
+func newSecretResolver(cfgFileDir string, params map[string]interface{}) *secretResolver {
+	return &secretResolver{
+		cfgFileDir: cfgFileDir,
+		params:     params,
+		sensitive:  make(map[string]bool),
+	}
+}
+
+// commandDirKey is added to 'fromCommand' sources of config files extended from another
+// directory. Its value is the directory of the defining config file relative to the directory of
+// the including one. Not being a string, it cannot be specified in YAML.
+type commandDirKey struct{}
+
+// secretSource returns the type, spec, and command directory of a secret source such as
+// '{ fromFile: secret.txt }'. If value is not a secret source, false is returned.
+func secretSource(value interface{}) (string, interface{}, string, bool) {
+	source, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return "", nil, "", false
+	}
+	dir, hasDir := source[commandDirKey{}].(string)
+	if len(source) != 1 && !(hasDir && len(source) == 2) {
+		return "", nil, "", false
+	}
+	for k, v := range source {
+		if k == (commandDirKey{}) {
+			continue
+		}
+		sourceType := fmt.Sprint(k)
+		if sourceType != fromCommandKey && sourceType != fromFileKey && sourceType != fromEnvKey {
+			return "", nil, "", false
+		}
+		if hasDir && sourceType != fromCommandKey {
+			return "", nil, "", false
+		}
+		return sourceType, v, dir, true
+	}
+	return "", nil, "", false
+}
+
+// rebaseSecretSources makes relative paths of 'fromFile' sources relative to the directory of the
+// including config file and records the directory for 'fromCommand' sources. rel is the
+// directory of the extended config file relative to the including one.
+func rebaseSecretSources(value interface{}, rel string) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		if sourceType, spec, dir, ok := secretSource(v); ok {
+			s, isString := spec.(string)
+			switch {
+			case !isString:
+				return v
+			case sourceType == fromFileKey && !filepath.IsAbs(s):
+				// no filepath.Join here because it would clean paths inside of templates
+				return map[interface{}]interface{}{fromFileKey: rel + "/" + s}
+			case sourceType == fromCommandKey:
+				if dir != "" {
+					rel = rel + "/" + dir
+				}
+				return map[interface{}]interface{}{fromCommandKey: s, commandDirKey{}: rel}
+			}
+			return v
+		}
+		result := make(map[interface{}]interface{}, len(v))
+		for key, elem := range v {
+			result[key] = rebaseSecretSources(elem, rel)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			result[i] = rebaseSecretSources(elem, rel)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// resolve returns the resolved value and true if value is a secret source.
+// Otherwise, it returns false.
+func (r *secretResolver) resolve(value interface{}) (string, bool, error) {
+	sourceType, specValue, dir, ok := secretSource(value)
+	if !ok {
+		return "", false, nil
+	}
+	spec, ok := specValue.(string)
+	if !ok {
+		return "", false, fmt.Errorf("value of %q must be a string", sourceType)
+	}
+
+	params := r.params
+	if sourceType == fromCommandKey {
+		// params may be user input and must not be able to inject shell commands
+		quoted, err := shellQuoteParams(params)
+		if err != nil {
+			return "", false, err
+		}
+		params = quoted
+	}
+	spec, err := renderTemplate(map[string]interface{}{"Params": params}, spec)
+	if err != nil {
+		return "", false, err
+	}
+
+	if r.skip {
+		return fmt.Sprintf("<%s %s>", sourceType, spec), true, nil
+	}
+
+	var result string
+	switch sourceType {
+	case fromCommandKey:
+		result, err = r.fromCommand(spec, dir)
+	case fromFileKey:
+		result, err = r.fromFile(spec)
+	case fromEnvKey:
+		var ok bool
+		if result, ok = os.LookupEnv(spec); !ok {
+			err = fmt.Errorf("environment variable %q is not set", spec)
+		}
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("could not resolve secret %s %q: %w", sourceType, spec, err)
+	}
+
+	r.markSensitive(result)
+	return result, true, nil
+}
+
+// unwrapSensitive returns the inner value of '{ value: ..., sensitive: true }' and whether
+// it is marked as sensitive. If value is not of this form, false is returned as third value.
+func unwrapSensitive(value interface{}) (interface{}, bool, bool) {
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, false, false
+	}
+	inner, ok := m["value"]
+	if !ok {
+		return nil, false, false
+	}
+	for k := range m {
+		if k != "value" && k != "sensitive" {
+			return nil, false, false
+		}
+	}
+	sensitive, _ := m["sensitive"].(bool)
+	return inner, sensitive, true
+}
+
+// fromCommand runs the command in the directory of the config file defining it, which is dir
+// relative to the directory of the config file if it was extended from another directory.
+func (r *secretResolver) fromCommand(command string, dir string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = filepath.Join(r.cfgFileDir, filepath.FromSlash(dir))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// shellQuoteParams returns a copy of the params with string values quoted for the shell
+// running 'fromCommand'. Values only consisting of safe characters are not quoted, so they can
+// still be compared in templates.
+func shellQuoteParams(params map[string]interface{}) (map[string]interface{}, error) {
+	quoted := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		s, ok := v.(string)
+		if !ok || shellSafeRegex.MatchString(s) {
+			quoted[k] = v
+			continue
+		}
+		if runtime.GOOS == "windows" {
+			// cmd.exe expands '%' and '!' even within double quotes
+			if strings.ContainsAny(s, "\"%!\r\n") {
+				return nil, fmt.Errorf("value of param %q cannot be quoted safely for fromCommand", k)
+			}
+			quoted[k] = `"` + s + `"`
+			continue
+		}
+		quoted[k] = "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+	return quoted, nil
+}
+
+func (r *secretResolver) fromFile(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.cfgFileDir, path)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func (r *secretResolver) markSensitive(value string) {
+	if value != "" {
+		r.sensitive[value] = true
+	}
+}
+
+// sensitiveValues returns the sorted list of sensitive values or nil if there are none.
+func (r *secretResolver) sensitiveValues() []string {
+	if len(r.sensitive) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(r.sensitive))
+	for v := range r.sensitive {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func maybeAppendVarsFromSopsFile(cfg *Config, secrets *secretResolver, ignoreMissingVarFiles bool, varFilePath string, modulePath string) error {
+	var path string
+	if filepath.IsAbs(varFilePath) {
+		path = varFilePath
+	} else {
+		path = filepath.Join(modulePath, varFilePath)
+	}
+
+	if ignoreMissingVarFiles {
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				log.Println(fmt.Sprintf("File %s does not exist. Ignoring it.", path))
+				return nil
+			}
+		}
+	}
+
+	if secrets.skip {
+		_, err := os.Stat(path)
+		return err
+	}
+
+	cmd := exec.Command("sops", "--decrypt", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	decrypted, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("could not decrypt %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	setVar := func(name string, key string, value string) {
+		cfg.setVar(name, value, Origin{Source: SourceVarsFromSopsFiles, File: path, Key: key})
+		secrets.markSensitive(value)
+	}
+
+	switch filepath.Ext(path) {
+	case ".env":
+		envs, err := godotenv.Unmarshal(string(decrypted))
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", path, err)
+		}
+		for key, value := range envs {
+			setVar(strings.ToLower(key), key, value)
+		}
+	default:
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(decrypted, &values); err != nil {
+			return fmt.Errorf("could not parse %s: %w", path, err)
+		}
+		for key, value := range values {
+			if !isStructured(value) {
+				setVar(key, key, fmt.Sprint(value))
+				continue
+			}
+			// decrypted values are data rather than templates
+			converted, err := toJSONValue(value, func(s string) (string, error) {
+				secrets.markSensitive(s)
+				return s, nil
+			}, nil)
+			if err != nil {
+				return fmt.Errorf("could not convert %s from %s: %w", key, path, err)
+			}
+			encoded, err := encodeJSON(converted)
+			if err != nil {
+				return fmt.Errorf("could not convert %s from %s: %w", key, path, err)
+			}
+			setVar(key, key, encoded)
+			cfg.JSONVars[key] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var (
+	typeErrorRegex = regexp.MustCompile(`^line (\d+): (.*)$`)
+	// validKeys are the keys allowed at the top level of a config file
+	validKeys = yamlKeys(reflect.TypeOf(fileConfig{}))
+	// paramSpecKeys are the keys allowed in param specs under 'requiredParams'
+	paramSpecKeys = yamlKeys(reflect.TypeOf(ParamSpec{}))
+)
+
+// checkStrict checks the config data for unknown keys and values of the wrong type. All
+// violations are reported at once along with their line and column.
+func checkStrict(cfgData []byte) error {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(cfgData, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yamlv3.MappingNode {
+		return nil
+	}
+	root := doc.Content[0]
+
+	violations := checkKeys(root, validKeys, "")
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "requiredParams" || root.Content[i+1].Kind != yamlv3.MappingNode {
+			continue
+		}
+		params := root.Content[i+1]
+		for j := 0; j+1 < len(params.Content); j += 2 {
+			if spec := params.Content[j+1]; spec.Kind == yamlv3.MappingNode {
+				violations = append(violations, checkKeys(spec, paramSpecKeys, " in requiredParams."+params.Content[j].Value)...)
+			}
+		}
+	}
+
+	var cfg fileConfig
+	var typeErr *yaml.TypeError
+	if err := yaml.UnmarshalStrict(cfgData, &cfg); errors.As(err, &typeErr) {
+		for _, e := range typeErr.Errors {
+			m := typeErrorRegex.FindStringSubmatch(e)
+			if m == nil {
+				violations = append(violations, e)
+				continue
+			}
+			// unknown keys have already been reported above
+			if strings.Contains(m[2], " not found in type config.fileConfig") || strings.Contains(m[2], " not found in type config.paramSpec") {
+				continue
+			}
+			line, _ := strconv.Atoi(m[1])
+			if column := valueColumn(root, line); column > 0 {
+				violations = append(violations, fmt.Sprintf("line %d, column %d: %s", line, column, m[2]))
+			} else {
+				violations = append(violations, e)
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(violations, "\n  "))
+	}
+	return nil
+}
+
+// valueColumn returns the column of the first value node on the specified line or 0 if there is none.
+func valueColumn(node *yamlv3.Node, line int) int {
+	for i, child := range node.Content {
+		// skip keys of mappings
+		isKey := node.Kind == yamlv3.MappingNode && i%2 == 0
+		if !isKey && child.Line == line {
+			return child.Column
+		}
+		if column := valueColumn(child, line); column > 0 {
+			return column
+		}
+	}
+	return 0
+}
+
+// checkKeys returns violations for keys of the mapping node which are not valid.
+func checkKeys(node *yamlv3.Node, valid []string, context string) []string {
+	var violations []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if isValidKey(key.Value, valid) {
+			continue
+		}
+		msg := fmt.Sprintf("line %d, column %d: unknown key %q%s", key.Line, key.Column, key.Value, context)
+		if suggestion := closestKey(key.Value, valid); suggestion != "" {
+			msg += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		violations = append(violations, msg)
+	}
+	return violations
+}
+
+func isValidKey(key string, valid []string) bool {
+	for _, k := range valid {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// closestKey returns the valid key most similar to key or an empty string if none is similar enough.
+func closestKey(key string, valid []string) string {
+	var result string
+	best := len(key)/3 + 1
+	for _, k := range valid {
+		if d := levenshtein(strings.ToLower(key), strings.ToLower(k)); d <= best {
+			best = d
+			result = k
+		}
+	}
+	return result
+}
+
+func levenshtein(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func yamlKeys(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag != "" && tag != "-" {
+			keys = append(keys, tag)
+		}
+	}
+	return keys
+}
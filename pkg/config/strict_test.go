@@ -0,0 +1,54 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Strict(t *testing.T) {
+	_, err := Load("testdata/strict/typos.yaml", "testmodule1", nil)
+	require.Error(t, err)
+	assert.Equal(t, `invalid config:
+  line 1, column 1: unknown key "globalVar", did you mean "globalVars"?
+  line 4, column 1: unknown key "moduleVarfiles", did you mean "moduleVarFiles"?
+  line 10, column 1: unknown key "totallyUnrelated"
+  line 8, column 24: cannot unmarshal !!str `+"`maybe`"+` into bool`, err.Error())
+
+	got, err := Load("testdata/strict/non-strict.yaml", "testmodule1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"foo": "bar"}, got.Vars)
+}
+
+func TestClosestKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "globalVar", want: "globalVars"},
+		{key: "GlobalVars", want: "globalVars"},
+		{key: "backendConfig", want: "backendConfigs"},
+		{key: "envs2", want: "envs"},
+		{key: "foo", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, closestKey(tt.key, validKeys))
+		})
+	}
+}
@@ -0,0 +1,582 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"gopkg.in/yaml.v2"
+)
+
+// Schema is the JSON Schema describing config files.
+//
+//go:embed gotf.schema.json
+var Schema []byte
+
+const schemaFileName = "gotf.schema.json"
+
+// ValidationError lists all problems found in a config file.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d problem(s) found:\n  %s", len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+// Validate checks that the config file matches Schema, its templates only reference defined params,
+// and its var files exist for all allowed param values. Problems are returned as *ValidationError.
+func Validate(configFile string) error {
+	fileCfg, err := loadFile(configFile, nil)
+	if err != nil {
+		return err
+	}
+	schemaProblems, err := validateSchema(configFile)
+	if err != nil {
+		return err
+	}
+
+	v := &validator{
+		cfg:        fileCfg,
+		cfgFileDir: filepath.Dir(configFile),
+		templates:  make(map[string]*parse.Tree),
+		seen:       make(map[string]bool),
+	}
+	for _, p := range schemaProblems {
+		v.addProblem("%s", p)
+	}
+	v.checkRequiredParams()
+	v.checkModuleKeys()
+	v.checkTemplates()
+	v.checkVarFiles()
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validateSchema returns the violations of Schema found in the config file. Files which cannot
+// be parsed are left to loadFile to report. Unknown keys are ignored if strict parsing is disabled.
+func validateSchema(configFile string) ([]string, error) {
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, nil
+	}
+	doc, err := toJSONValue(raw, func(s string) (string, error) { return s, nil }, nil)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := doc.(map[string]interface{}); ok {
+		if strict, ok := m["strict"].(bool); ok && !strict {
+			for k := range m {
+				if !isValidKey(k, validKeys) {
+					delete(m, k)
+				}
+			}
+		}
+	}
+
+	schemaDoc, err := jsonschema.UnmarshalJSON(bytes.NewReader(Schema))
+	if err != nil {
+		return nil, err
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(schemaFileName, schemaDoc); err != nil {
+		return nil, err
+	}
+	schema, err := compiler.Compile(schemaFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if err := schema.Validate(doc); !errors.As(err, &validationErr) {
+		return nil, err
+	}
+	var problems []string
+	for _, e := range schemaErrors(validationErr) {
+		location := strings.Join(e.InstanceLocation, ".")
+		if location == "" {
+			location = "config"
+		}
+		problems = append(problems, fmt.Sprintf("%s: %s", location, e.BasicOutput().Error))
+	}
+	return problems, nil
+}
+
+// schemaErrors returns the most specific errors of the validation error. Alternatives of 'oneOf'
+// and 'anyOf' which failed only because of their type are omitted unless all of them did.
+func schemaErrors(e *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if _, ok := e.ErrorKind.(*kind.PropertyNames); ok {
+		// property names are validated as separate instances without location, so the location
+		// of the object is taken from the schema location, e.g. '#/properties/params/propertyNames'
+		var location []string
+		_, fragment, _ := strings.Cut(e.SchemaURL, "#")
+		tokens := strings.Split(fragment, "/")
+		for i := 0; i+1 < len(tokens); i++ {
+			if tokens[i] == "properties" {
+				location = append(location, tokens[i+1])
+				i++
+			}
+		}
+		return []*jsonschema.ValidationError{{InstanceLocation: location, ErrorKind: e.ErrorKind}}
+	}
+	if len(e.Causes) == 0 {
+		// patterns are checked using Go's regexp syntax instead
+		if f, ok := e.ErrorKind.(*kind.Format); ok && f.Want == "regex" {
+			return nil
+		}
+		return []*jsonschema.ValidationError{e}
+	}
+
+	var result []*jsonschema.ValidationError
+	switch e.ErrorKind.(type) {
+	case *kind.OneOf, *kind.AnyOf:
+		typeErrorsOnly := true
+		for _, c := range e.Causes {
+			if !isTypeError(c) {
+				typeErrorsOnly = false
+				result = append(result, schemaErrors(c)...)
+			}
+		}
+		if typeErrorsOnly {
+			return []*jsonschema.ValidationError{{InstanceLocation: e.InstanceLocation, ErrorKind: e.ErrorKind}}
+		}
+		return result
+	}
+	for _, c := range e.Causes {
+		result = append(result, schemaErrors(c)...)
+	}
+	return result
+}
+
+// isTypeError reports whether the error is a type mismatch, possibly of a referenced schema.
+func isTypeError(e *jsonschema.ValidationError) bool {
+	for {
+		if _, ok := e.ErrorKind.(*kind.Type); ok {
+			return true
+		}
+		if len(e.Causes) != 1 {
+			return false
+		}
+		e = e.Causes[0]
+	}
+}
+
+type validator struct {
+	cfg        *fileConfig
+	cfgFileDir string
+	// templates holds successfully parsed templates by location, e.g. 'globalVars.foo'
+	templates map[string]*parse.Tree
+	problems  []string
+	seen      map[string]bool
+}
+
+// varFile is a var file entry in the config file.
+type varFile struct {
+	location string
+	template string
+	module   string
+}
+
+func (v *validator) addProblem(format string, args ...interface{}) {
+	problem := fmt.Sprintf(format, args...)
+	if !v.seen[problem] {
+		v.seen[problem] = true
+		v.problems = append(v.problems, problem)
+	}
+}
+
+func (v *validator) varFiles() []varFile {
+	var files []varFile
+	appendFiles := func(section string, templates []string, module string) {
+		for i, f := range templates {
+			files = append(files, varFile{location: fmt.Sprintf("%s[%d]", section, i), template: f, module: module})
+		}
+	}
+	appendFiles("globalVarFiles", v.cfg.GlobalVarFiles, "")
+	for _, key := range sortedKeys(v.cfg.ModuleVarFiles) {
+		// the module directory is only known for keys which aren't patterns
+		var module string
+		if k, err := parseModuleKey(key); err == nil && (k.kind == moduleKeyExact || k.kind == moduleKeyPath) {
+			module = key
+		}
+		appendFiles("moduleVarFiles."+key, v.cfg.ModuleVarFiles[key], module)
+	}
+	appendFiles("varsFromEnvFiles", v.cfg.VarsFromEnvFiles, "")
+	appendFiles("varsFromSopsFiles", v.cfg.VarsFromSopsFiles, "")
+	return files
+}
+
+func (v *validator) checkRequiredParams() {
+	for _, name := range sortedKeys(v.cfg.RequiredParams) {
+		spec := v.cfg.RequiredParams[name]
+		if spec.Pattern != "" {
+			if _, err := spec.compilePattern(); err != nil {
+				v.addProblem("requiredParams.%s: %v", name, err)
+				continue
+			}
+		}
+		if spec.Optional() {
+			if err := spec.Check(*spec.Default); err != nil {
+				v.addProblem("requiredParams.%s: default value %q %v", name, *spec.Default, err)
+			}
+		}
+	}
+}
+
+func (v *validator) checkModuleKeys() {
+	check := func(section string, keys []string) {
+		for _, key := range keys {
+			if _, err := parseModuleKey(key); err != nil {
+				v.addProblem("%s.%s: %v", section, key, err)
+			}
+		}
+	}
+	check("moduleVarFiles", sortedKeys(v.cfg.ModuleVarFiles))
+	check("moduleVars", sortedKeys(v.cfg.ModuleVars))
+}
+
+func (v *validator) checkTemplates() {
+	for _, f := range v.varFiles() {
+		v.checkTemplate(f.location, f.template)
+	}
+	checkValues := func(section string, values map[string]interface{}) {
+		for _, k := range sortedKeys(values) {
+			for _, tmpl := range valueTemplates(values[k]) {
+				v.checkTemplate(section+"."+k, tmpl)
+			}
+		}
+	}
+	checkValues("globalVars", v.cfg.GlobalVars)
+	for _, module := range sortedKeys(v.cfg.ModuleVars) {
+		checkValues("moduleVars."+module, v.cfg.ModuleVars[module])
+	}
+	checkValues("envs", v.cfg.Envs)
+	checkValues("backendConfigs", v.cfg.BackendConfigs)
+}
+
+func (v *validator) checkTemplate(location string, tmpl string) {
+	tpl, err := newTemplate().Parse(tmpl)
+	if err != nil {
+		v.addProblem("%s: invalid template: %v", location, err)
+		return
+	}
+	v.templates[location] = tpl.Tree
+	for _, ref := range paramRefs(tpl.Tree) {
+		if !v.isParamDefined(ref) {
+			v.addProblem("%s: param %q is not defined in 'params' or 'requiredParams'", location, ref)
+		}
+	}
+}
+
+func (v *validator) isParamDefined(name string) bool {
+	if name == moduleDirParamName || name == modulePathParamName {
+		return true
+	}
+	if _, ok := v.cfg.Params[name]; ok {
+		return true
+	}
+	_, ok := v.cfg.RequiredParams[name]
+	return ok
+}
+
+// checkVarFiles checks that var files exist for each combination of allowed values of required
+// params. Var files referencing required params without allowed values cannot be checked.
+func (v *validator) checkVarFiles() {
+	if v.cfg.IgnoreMissingVarFiles {
+		return
+	}
+	for _, combination := range paramCombinations(allowedValues(v.cfg.RequiredParams)) {
+		for _, f := range v.varFiles() {
+			tree, ok := v.templates[f.location]
+			if !ok {
+				continue
+			}
+			params := make(map[string]interface{}, len(v.cfg.Params)+len(combination)+1)
+			for k, value := range v.cfg.Params {
+				params[k] = value
+			}
+			for k, spec := range v.cfg.RequiredParams {
+				if spec.Optional() {
+					params[k] = *spec.Default
+				}
+			}
+			for k, value := range combination {
+				params[k] = value
+			}
+			if f.module != "" {
+				params[moduleDirParamName] = path.Base(f.module)
+				if strings.Contains(f.module, "/") {
+					params[modulePathParamName] = path.Clean(f.module)
+				}
+			}
+
+			refs := paramRefs(tree)
+			if !hasAll(params, refs) {
+				continue
+			}
+			path, err := renderTemplate(map[string]interface{}{"Params": params}, f.template)
+			if err != nil {
+				v.addProblem("%s: %v", f.location, err)
+				continue
+			}
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(v.cfgFileDir, path)
+			}
+			if _, err := os.Stat(path); err != nil {
+				if os.IsNotExist(err) {
+					v.addProblem("%s: file %s does not exist%s", f.location, path, describeCombination(combination, refs))
+				} else {
+					v.addProblem("%s: %v", f.location, err)
+				}
+			}
+		}
+	}
+}
+
+// ValidateCombinations loads the config for each module directory and each combination of allowed
+// values of required params. Required params specified in params are fixed to the specified value.
+// Secrets are not resolved. If loading fails for any combination, a *ValidationError is returned.
+// Otherwise, the number of checked combinations is returned.
+func ValidateCombinations(configFile string, moduleDirs []string, params map[string]string) (int, error) {
+	fileCfg, err := loadFile(configFile, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	requiredParams := allowedValues(fileCfg.RequiredParams)
+	for name := range requiredParams {
+		if value, ok := params[name]; ok {
+			requiredParams[name] = []string{value}
+		}
+	}
+
+	var checked int
+	var problems []string
+	for _, moduleDir := range moduleDirs {
+		for _, combination := range paramCombinations(requiredParams) {
+			cliParams := make(map[string]string, len(params)+len(combination))
+			for k, v := range params {
+				cliParams[k] = v
+			}
+			for k, v := range combination {
+				cliParams[k] = v
+			}
+			checked++
+			prefix := fmt.Sprintf("module %s%s", moduleDir, describeCombination(combination, sortedKeys(combination)))
+			cfg, err := loadConfig(configFile, moduleDir, cliParams, false)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+				continue
+			}
+			for _, f := range cfg.VarFiles {
+				path := f
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(moduleDir, path)
+				}
+				if _, err := os.Stat(path); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+				}
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return checked, &ValidationError{Problems: problems}
+	}
+	return checked, nil
+}
+
+// allowedValues returns the allowed values of required params.
+func allowedValues(specs map[string]ParamSpec) map[string][]string {
+	result := make(map[string][]string, len(specs))
+	for name, spec := range specs {
+		result[name] = spec.Values
+	}
+	return result
+}
+
+// paramCombinations returns all combinations of allowed values of required params. Required
+// params without allowed values are not included.
+func paramCombinations(requiredParams map[string][]string) []map[string]string {
+	combinations := []map[string]string{{}}
+	for _, name := range sortedKeys(requiredParams) {
+		values := requiredParams[name]
+		if len(values) == 0 {
+			continue
+		}
+		var next []map[string]string
+		for _, c := range combinations {
+			for _, value := range values {
+				combination := make(map[string]string, len(c)+1)
+				for k, v := range c {
+					combination[k] = v
+				}
+				combination[name] = value
+				next = append(next, combination)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// describeCombination describes the values of the referenced params in the combination, e.g. ' (environment=dev)'.
+func describeCombination(combination map[string]string, refs []string) string {
+	var parts []string
+	for _, ref := range refs {
+		if value, ok := combination[ref]; ok {
+			parts = append(parts, ref+"="+value)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// valueTemplates returns the templates contained in a value, i.e. the value itself if it is a
+// string or the spec of a secret source.
+func valueTemplates(value interface{}) []string {
+	if inner, _, ok := unwrapSensitive(value); ok {
+		return valueTemplates(inner)
+	}
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case map[interface{}]interface{}:
+		if _, spec, _, ok := secretSource(v); ok {
+			if s, ok := spec.(string); ok {
+				return []string{s}
+			}
+		}
+		// strings in structured values are rendered as templates as well
+		keys := make([]interface{}, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		var templates []string
+		for _, key := range keys {
+			templates = append(templates, valueTemplates(v[key])...)
+		}
+		return templates
+	case []interface{}:
+		var templates []string
+		for _, elem := range v {
+			templates = append(templates, valueTemplates(elem)...)
+		}
+		return templates
+	}
+	return nil
+}
+
+// paramRefs returns the sorted names of params referenced via '.Params.<name>' in the template.
+func paramRefs(tree *parse.Tree) []string {
+	refs := make(map[string]bool)
+	var walk func(node parse.Node)
+	addRef := func(ident []string) {
+		if len(ident) >= 2 && ident[0] == "Params" {
+			refs[ident[1]] = true
+		}
+	}
+	walkBranch := func(n *parse.BranchNode) {
+		walk(n.Pipe)
+		walk(n.List)
+		walk(n.ElseList)
+	}
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			addRef(n.Ident)
+		case *parse.VariableNode:
+			if len(n.Ident) > 0 && n.Ident[0] == "$" {
+				addRef(n.Ident[1:])
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.IfNode:
+			walkBranch(&n.BranchNode)
+		case *parse.RangeNode:
+			walkBranch(&n.BranchNode)
+		case *parse.WithNode:
+			walkBranch(&n.BranchNode)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+	walk(tree.Root)
+
+	result := make([]string, 0, len(refs))
+	for ref := range refs {
+		result = append(result, ref)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func hasAll(params map[string]interface{}, names []string) bool {
+	for _, name := range names {
+		if _, ok := params[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
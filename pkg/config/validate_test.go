@@ -0,0 +1,145 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		configFile   string
+		wantProblems []string
+		wantErrMsg   string
+	}{
+		{
+			name:       "valid",
+			configFile: "testdata/validate/valid.yaml",
+		},
+		{
+			name:       "invalid",
+			configFile: "testdata/validate/invalid.yaml",
+			wantProblems: []string{
+				"moduleVars.~services/(svc_[a-z]+: invalid regular expression in module key \"~services/(svc_[a-z]+\": error parsing regexp: missing closing ): `^(?:services/(svc_[a-z]+)$`",
+				`globalVars.broken: invalid template: template: gotpl:1: unclosed action`,
+				`globalVars.location: param "region" is not defined in 'params' or 'requiredParams'`,
+				`globalVars.password: param "team" is not defined in 'params' or 'requiredParams'`,
+				`globalVars.tags: param "owner" is not defined in 'params' or 'requiredParams'`,
+				`globalVarFiles[1]: file testdata/validate/common.tfvars does not exist`,
+				`moduleVarFiles.app[0]: file testdata/validate/app/app-prod.tfvars does not exist (environment=prod)`,
+				`moduleVarFiles.services/api[0]: file testdata/validate/services/api/api.tfvars does not exist`,
+				`globalVarFiles[0]: file testdata/validate/env-prod.tfvars does not exist (environment=prod)`,
+			},
+		},
+		{
+			name:       "invalid param specs",
+			configFile: "testdata/validate/params.yaml",
+			wantProblems: []string{
+				"requiredParams.environment: invalid pattern \"feature-[a-z\": error parsing regexp: missing closing ]: `[a-z)$`",
+				`requiredParams.region: default value "eastus" must be one of [westeurope]`,
+			},
+		},
+		{
+			name:       "schema",
+			configFile: "testdata/validate/schema.yaml",
+			wantProblems: []string{
+				"varsMode: value must be one of 'env', 'file'",
+				"params: invalid propertyName 'modulePath'",
+			},
+		},
+		{
+			name:       "unknown keys not strict",
+			configFile: "testdata/strict/non-strict.yaml",
+		},
+		{
+			name:       "strict",
+			configFile: "testdata/strict/typos.yaml",
+			wantErrMsg: `invalid config:
+  line 1, column 1: unknown key "globalVar", did you mean "globalVars"?
+  line 4, column 1: unknown key "moduleVarfiles", did you mean "moduleVarFiles"?
+  line 10, column 1: unknown key "totallyUnrelated"
+  line 8, column 24: cannot unmarshal !!str ` + "`maybe`" + ` into bool`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.configFile)
+			switch {
+			case tt.wantProblems != nil:
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.ElementsMatch(t, tt.wantProblems, validationErr.Problems)
+			case tt.wantErrMsg != "":
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErrMsg, err.Error())
+			default:
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateCombinations(t *testing.T) {
+	checked, err := ValidateCombinations("testdata/validate/combinations.yaml", []string{"testdata/validate/app"}, map[string]string{"owner": "me"})
+	assert.Equal(t, 2, checked)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		"module testdata/validate/app (environment=prod, owner=me): stat testdata/validate/app/app-prod.tfvars: no such file or directory",
+	}, validationErr.Problems)
+
+	checked, err = ValidateCombinations("testdata/validate/combinations.yaml", []string{"testdata/validate/app"}, map[string]string{"owner": "me", "environment": "dev"})
+	assert.Equal(t, 1, checked)
+	require.NoError(t, err)
+
+	_, err = ValidateCombinations("testdata/validate/combinations.yaml", []string{"testdata/validate/app"}, nil)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		`module testdata/validate/app (environment=dev): required parameter "owner" must be specified`,
+		`module testdata/validate/app (environment=prod): required parameter "owner" must be specified`,
+	}, validationErr.Problems)
+}
+
+func TestSchema(t *testing.T) {
+	type object struct {
+		Properties map[string]interface{} `json:"properties"`
+	}
+	var schema struct {
+		object
+		Definitions struct {
+			ParamSpec object `json:"paramSpec"`
+		} `json:"definitions"`
+	}
+	require.NoError(t, json.Unmarshal(Schema, &schema))
+
+	assertSameKeys := func(keys []string, properties map[string]interface{}, msg string) {
+		names := make([]string, 0, len(properties))
+		for k := range properties {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		keys = append([]string{}, keys...)
+		sort.Strings(keys)
+		assert.Equal(t, keys, names, msg)
+	}
+	assertSameKeys(validKeys, schema.Properties, "schema must describe all config keys")
+	assertSameKeys(paramSpecKeys, schema.Definitions.ParamSpec.Properties, "schema must describe all param spec keys")
+}
@@ -0,0 +1,103 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// isStructured reports whether the value is a YAML map or list.
+func isStructured(value interface{}) bool {
+	switch value.(type) {
+	case map[interface{}]interface{}, map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// renderStructuredValue renders a YAML map or list as JSON, which Terraform accepts for
+// complex values of 'TF_VAR_' environment variables. Strings are rendered as templates and
+// nested secret sources are resolved.
+func renderStructuredValue(value interface{}, params map[string]interface{}, secrets *secretResolver) (string, error) {
+	converted, err := toJSONValue(value, func(tmpl string) (string, error) {
+		return renderTemplate(map[string]interface{}{"Params": params}, tmpl)
+	}, secrets.resolve)
+	if err != nil {
+		return "", err
+	}
+	return encodeJSON(converted)
+}
+
+// encodeJSON encodes a value converted with toJSONValue as compact JSON.
+func encodeJSON(value interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// Terraform doesn't need HTML-safe JSON, and escaped '<', '>', and '&' are hard to read
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// toJSONValue converts a value decoded from YAML to a value which can be encoded as JSON.
+// Map keys are converted to strings and strings are passed to render. If resolve is not nil,
+// maps it reports as secret sources are replaced with the resolved value.
+func toJSONValue(value interface{}, render func(string) (string, error), resolve func(interface{}) (string, bool, error)) (interface{}, error) {
+	if resolve != nil {
+		if secret, ok, err := resolve(value); ok || err != nil {
+			return secret, err
+		}
+	}
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			converted, err := toJSONValue(elem, render, resolve)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", key, err)
+			}
+			result[fmt.Sprint(key)] = converted
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			converted, err := toJSONValue(elem, render, resolve)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			result[key] = converted
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			converted, err := toJSONValue(elem, render, resolve)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			result[i] = converted
+		}
+		return result, nil
+	case string:
+		return render(v)
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestComputeValue_Structured(t *testing.T) {
+	t.Setenv("GOTF_TEST_NESTED_PASSWORD", "s3cr3t")
+	t.Setenv("GOTF_TEST_NESTED_HOST", "db.example.com")
+	os.Unsetenv("GOTF_TEST_NESTED_MISSING")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "scalar",
+			value: `42`,
+			want:  `42`,
+		},
+		{
+			name:  "list",
+			value: `[a, 1, 1.5, true, null]`,
+			want:  `["a",1,1.5,true,null]`,
+		},
+		{
+			name:  "map",
+			value: `{b: 2, a: "1", c: false}`,
+			want:  `{"a":"1","b":2,"c":false}`,
+		},
+		{
+			name: "nested",
+			value: `
+entry1:
+  value1: testvalue1
+  value2: true
+  tags: [x, z]
+entry2:
+  - name: foo
+    ports: [80, 443]`,
+			want: `{"entry1":{"tags":["x","z"],"value1":"testvalue1","value2":true},"entry2":[{"name":"foo","ports":[80,443]}]}`,
+		},
+		{
+			name:  "quoting",
+			value: `{text: "say \"hi\" <b>&</b>", path: 'C:\dir', multiline: "a\nb"}`,
+			want:  `{"multiline":"a\nb","path":"C:\\dir","text":"say \"hi\" <b>&</b>"}`,
+		},
+		{
+			name:  "non-string keys",
+			value: `{1: one, true: two}`,
+			want:  `{"1":"one","true":"two"}`,
+		},
+		{
+			name:  "templates",
+			value: `{env: "{{ .Params.environment }}", names: ["{{ .Params.environment }}-a"]}`,
+			want:  `{"env":"dev","names":["dev-a"]}`,
+		},
+		{
+			name:  "nested secrets",
+			value: `{user: admin, password: {fromEnv: GOTF_TEST_NESTED_PASSWORD}, hosts: [{fromEnv: GOTF_TEST_NESTED_HOST}]}`,
+			want:  `{"hosts":["db.example.com"],"password":"s3cr3t","user":"admin"}`,
+		},
+		{
+			name:    "nested secret not set",
+			value:   `{db: {password: {fromEnv: GOTF_TEST_NESTED_MISSING}}}`,
+			wantErr: `db: password: could not resolve secret fromEnv "GOTF_TEST_NESTED_MISSING": environment variable "GOTF_TEST_NESTED_MISSING" is not set`,
+		},
+		{
+			name:    "invalid template",
+			value:   `{outer: {inner: ["{{ .Params.missing }}"]}}`,
+			wantErr: `outer: inner: [0]: template: gotpl:1:10: executing "gotpl" at <.Params.missing>: map has no entry for key "missing"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			require.NoError(t, yaml.Unmarshal([]byte(tt.value), &value))
+			params := map[string]interface{}{"environment": "dev"}
+			got, err := computeValue(value, params, newSecretResolver(".", params))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoad_VarsMode(t *testing.T) {
+	got, err := Load("testdata/varsmode/file.yaml", "testdata/varsmode/app", nil)
+	require.NoError(t, err)
+	assert.Equal(t, VarsModeFile, got.VarsMode)
+	assert.Equal(t, map[string]string{
+		"region":        "westeurope",
+		"replicas":      "3",
+		"enabled":       "true",
+		"tags":          `{"environment":"dev"}`,
+		"overridden":    "one",
+		"from_env_file": "42",
+	}, got.Vars)
+	assert.Equal(t, map[string]bool{"replicas": true, "enabled": true, "tags": true}, got.JSONVars)
+
+	got, err = Load("testdata/test-config.yaml", "testmodule1", map[string]string{"environment": "dev"})
+	require.NoError(t, err)
+	assert.Equal(t, VarsModeEnv, got.VarsMode)
+
+	_, err = Load("testdata/varsmode/invalid.yaml", "testdata/varsmode/app", nil)
+	assert.EqualError(t, err, `invalid varsMode "files", must be "env" or "file"`)
+}
@@ -16,7 +16,6 @@ package gotf
 
 import (
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -48,16 +47,26 @@ var (
 		SHA256SumsFile:          "https://releases.hashicorp.com/terraform/%[1]s/terraform_%[1]s_SHA256SUMS",
 		SHA256SumsSignatureFile: "https://releases.hashicorp.com/terraform/%[1]s/terraform_%[1]s_SHA256SUMS.sig",
 	}
+
+	releaseIndexURL = "https://releases.hashicorp.com/terraform/index.json"
 )
 
 type Args struct {
-	Debug            bool
-	ConfigFile       string
-	ModuleDir        string
-	Params           map[string]string
+	Debug      bool
+	ConfigFile string
+	ModuleDir  string
+	Params     map[string]string
+	// ParamsFile is a YAML or dotenv file with params. Params specified in Params or via
+	// 'GOTF_PARAM_<NAME>' environment variables take precedence.
+	ParamsFile       string
 	SkipBackendCheck bool
 	NoVars           bool
-	Args             []string
+	// NoInput disables prompting for missing required params.
+	NoInput bool
+	// SavedPlan stores plans under '.gotf/plans' in the module directory when running 'plan'
+	// and applies the matching plan without adding vars when running 'apply'.
+	SavedPlan bool
+	Args      []string
 }
 
 func Run(args Args) error {
@@ -65,38 +74,65 @@ func Run(args Args) error {
 		return errors.New("no arguments for Terraform specified")
 	}
 
-	if args.Debug {
+	setupLogging(args.Debug)
+
+	var err error
+	if args.SavedPlan {
+		if args, err = savedPlanArgs(args); err != nil {
+			return err
+		}
+	}
+
+	cfg, tf, err := prepare(args, sh.Shell{})
+	if err != nil {
+		return err
+	}
+	var plan *savedPlan
+	if args.SavedPlan {
+		if plan, err = newSavedPlan(args, cfg); err != nil {
+			return err
+		}
+	}
+	return execute(tf, plan, args.Args)
+}
+
+// execute runs Terraform with the saved plan if specified.
+func execute(tf *terraform.Terraform, plan *savedPlan, args []string) error {
+	if plan == nil {
+		return tf.Execute(args...)
+	}
+	if err := plan.before(); err != nil {
+		return err
+	}
+	return plan.after(tf.Execute(plan.terraformArgs(args)...))
+}
+
+func setupLogging(debug bool) {
+	if debug {
 		log.SetOutput(os.Stderr)
 		log.SetFlags(0)
 		log.SetPrefix("gotf> ")
 	} else {
 		log.SetOutput(ioutil.Discard)
 	}
+}
+
+// prepare loads the config for the module and installs the required Terraform version.
+func prepare(args Args, shell terraform.Shell) (*config.Config, *terraform.Terraform, error) {
+	cfg, err := loadConfig(args)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	cfg, err := config.Load(args.ConfigFile, args.ModuleDir, args.Params)
+	versionConstraint, err := terraformVersion(cfg, args)
 	if err != nil {
-		return fmt.Errorf("could not load config file %q: %w", args.ConfigFile, err)
+		return nil, nil, err
 	}
 
 	var tfBinary string
-	if cfg.TerraformVersion != "" {
-		log.Println("Using Terraform version", cfg.TerraformVersion)
-		cacheDir, err := xdg.CacheFile(filepath.Join("gotf", "terraform", cfg.TerraformVersion))
-		if err != nil {
-			return err
-		}
-		tfBinary = filepath.Join(cacheDir, "terraform")
-		if _, err := os.Stat(tfBinary); err != nil {
-			if os.IsNotExist(err) {
-				installer := terraform.NewInstaller(urlTemplates, cfg.TerraformVersion, [][]byte{hashicorpPGPKeyNew, hashicorpPGPKeyOld}, cacheDir)
-				if err = installer.Install(runtime.GOOS, runtime.GOARCH); err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
-		} else {
-			log.Println("Terraform version", cfg.TerraformVersion, "already installed.")
+	if versionConstraint != "" {
+		if tfBinary, err = installTerraform(versionConstraint); err != nil {
+			return nil, nil, err
 		}
 	} else {
 		tfBinary = "terraform"
@@ -104,7 +140,63 @@ func Run(args Args) error {
 
 	log.Println("Terraform binary:", tfBinary)
 
-	shell := sh.Shell{}
 	tf := terraform.NewTerraform(cfg, args.ModuleDir, args.Params, args.SkipBackendCheck, args.NoVars, shell, tfBinary)
-	return tf.Execute(args.Args...)
+	return cfg, tf, nil
+}
+
+// terraformVersion returns the Terraform version or version constraint to use. If not set in the
+// config file, a '.terraform-version' file or the module's 'required_version' setting is used.
+func terraformVersion(cfg *config.Config, args Args) (string, error) {
+	if cfg.TerraformVersion != "" {
+		return cfg.TerraformVersion, nil
+	}
+
+	version, versionFile, err := terraform.FindVersionFile(args.ModuleDir, filepath.Dir(args.ConfigFile))
+	if err != nil {
+		return "", err
+	}
+	if version != "" {
+		log.Println("Using Terraform version from", versionFile)
+		return version, nil
+	}
+
+	requiredVersion, err := terraform.RequiredVersion(args.ModuleDir)
+	if err != nil {
+		return "", err
+	}
+	if requiredVersion != "" {
+		log.Println("Using Terraform version constraint from required_version:", requiredVersion)
+	}
+	return requiredVersion, nil
+}
+
+func installTerraform(versionConstraint string) (string, error) {
+	versionsDir := filepath.Join(xdg.CacheHome, "gotf", "terraform")
+	resolver := terraform.NewVersionResolver(releaseIndexURL, versionsDir)
+	version, err := resolver.Resolve(versionConstraint)
+	if err != nil {
+		return "", err
+	}
+	if version != versionConstraint {
+		log.Println("Resolved Terraform version constraint", versionConstraint, "to", version)
+	}
+
+	log.Println("Using Terraform version", version)
+	cacheDir, err := xdg.CacheFile(filepath.Join("gotf", "terraform", version))
+	if err != nil {
+		return "", err
+	}
+	tfBinary := filepath.Join(cacheDir, "terraform")
+	if _, err := os.Stat(tfBinary); err != nil {
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		installer := terraform.NewInstaller(urlTemplates, version, [][]byte{hashicorpPGPKeyNew, hashicorpPGPKeyOld}, cacheDir)
+		if err = installer.Install(runtime.GOOS, runtime.GOARCH); err != nil {
+			return "", err
+		}
+	} else {
+		log.Println("Terraform version", version, "already installed.")
+	}
+	return tfBinary, nil
 }
@@ -0,0 +1,158 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+
+	"github.com/craftypath/gotf/pkg/config"
+)
+
+// paramEnvPrefix is the prefix of environment variables specifying params, e.g. 'GOTF_PARAM_ENVIRONMENT'.
+const paramEnvPrefix = "GOTF_PARAM_"
+
+// loadConfig loads the config for the module. If required params are missing and input is
+// allowed, they are prompted for and added to args.Params, so they are only prompted for once.
+func loadConfig(args Args) (*config.Config, error) {
+	params, origins, err := resolveParams(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.Load(args.ConfigFile, args.ModuleDir, params)
+	var paramsErr *config.ParamsError
+	if errors.As(err, &paramsErr) && len(paramsErr.Missing) > 0 && !args.NoInput && args.Params != nil && isInteractive() {
+		p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+		for _, param := range paramsErr.Missing {
+			value, promptErr := p.prompt(param)
+			if promptErr != nil {
+				return nil, fmt.Errorf("could not read value for required parameter %q: %w", param.Name, promptErr)
+			}
+			args.Params[param.Name] = value
+			params[param.Name] = value
+		}
+		cfg, err = config.Load(args.ConfigFile, args.ModuleDir, params)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load config file %q: %w", args.ConfigFile, err)
+	}
+
+	for k, origin := range origins {
+		cfg.Origins.Params[k] = origin
+	}
+	return cfg, nil
+}
+
+// resolveParams merges params from the params file, 'GOTF_PARAM_<NAME>' environment variables,
+// and the command-line, in ascending order of precedence. Params specified in the config file
+// have the lowest precedence and are merged by config.Load. The origins of params not specified
+// on the command-line are returned as well.
+func resolveParams(args Args) (map[string]string, map[string]config.Origin, error) {
+	params := make(map[string]string)
+	origins := make(map[string]config.Origin)
+	paramName := paramNameMatcher(args.ConfigFile)
+
+	if args.ParamsFile != "" {
+		fileParams, err := readParamsFile(args.ParamsFile, paramName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read params file %q: %w", args.ParamsFile, err)
+		}
+		for k, v := range fileParams {
+			params[k] = v
+			origins[k] = config.Origin{Source: config.SourceParamsFile, File: args.ParamsFile, Key: k}
+		}
+	}
+
+	for _, env := range os.Environ() {
+		pair := strings.SplitN(env, "=", 2)
+		if len(pair) != 2 || !strings.HasPrefix(pair[0], paramEnvPrefix) || pair[0] == paramEnvPrefix {
+			continue
+		}
+		name := paramName(strings.TrimPrefix(pair[0], paramEnvPrefix))
+		params[name] = pair[1]
+		origins[name] = config.Origin{Source: config.SourceEnvironment, Key: pair[0]}
+	}
+
+	for k, v := range args.Params {
+		params[k] = v
+		delete(origins, k)
+	}
+	return params, origins, nil
+}
+
+// paramNameMatcher returns a function mapping names of params specified via environment variables
+// or dotenv files, which are usually upper-case, to the names of params declared in the config
+// file, ignoring case. Names of undeclared params are lower-cased.
+func paramNameMatcher(configFile string) func(string) string {
+	var declared []string
+	if configFile != "" {
+		var err error
+		// errors are reported when the config is loaded
+		if declared, err = config.ParamNames(configFile); err != nil {
+			log.Println("Could not determine declared params:", err)
+		}
+	}
+	return func(name string) string {
+		for _, d := range declared {
+			if strings.EqualFold(d, name) {
+				return d
+			}
+		}
+		return strings.ToLower(name)
+	}
+}
+
+// readParamsFile reads params from a dotenv file if its extension is '.env' or from a YAML file
+// otherwise. Names in dotenv files are mapped using paramName.
+func readParamsFile(path string, paramName func(string) string) (map[string]string, error) {
+	params := make(map[string]string)
+	if filepath.Ext(path) == ".env" {
+		envs, err := godotenv.Read(path)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range envs {
+			params[paramName(k)] = v
+		}
+		return params, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	for k, v := range values {
+		switch v.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return nil, fmt.Errorf("value of param %q must be a scalar", k)
+		}
+		params[k] = fmt.Sprint(v)
+	}
+	return params, nil
+}
@@ -0,0 +1,111 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/craftypath/gotf/pkg/config"
+)
+
+func TestResolveParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		configFile  string
+		paramsFile  string
+		envs        map[string]string
+		cliParams   map[string]string
+		want        map[string]string
+		wantOrigins map[string]config.Origin
+		wantErr     string
+	}{
+		{
+			name:        "cli only",
+			cliParams:   map[string]string{"environment": "dev"},
+			want:        map[string]string{"environment": "dev"},
+			wantOrigins: map[string]config.Origin{},
+		},
+		{
+			name:       "yaml file",
+			paramsFile: "testdata/params/params.yaml",
+			want:       map[string]string{"environment": "dev", "region": "westeurope", "replicas": "3"},
+			wantOrigins: map[string]config.Origin{
+				"environment": {Source: config.SourceParamsFile, File: "testdata/params/params.yaml", Key: "environment"},
+				"region":      {Source: config.SourceParamsFile, File: "testdata/params/params.yaml", Key: "region"},
+				"replicas":    {Source: config.SourceParamsFile, File: "testdata/params/params.yaml", Key: "replicas"},
+			},
+		},
+		{
+			name:       "dotenv file",
+			paramsFile: "testdata/params/params.env",
+			want:       map[string]string{"environment": "prod", "region": "northeurope"},
+			wantOrigins: map[string]config.Origin{
+				"environment": {Source: config.SourceParamsFile, File: "testdata/params/params.env", Key: "environment"},
+				"region":      {Source: config.SourceParamsFile, File: "testdata/params/params.env", Key: "region"},
+			},
+		},
+		{
+			name:       "precedence",
+			paramsFile: "testdata/params/params.yaml",
+			envs:       map[string]string{"GOTF_PARAM_REGION": "northeurope", "GOTF_PARAM_ENVIRONMENT": "prod"},
+			cliParams:  map[string]string{"environment": "test"},
+			want:       map[string]string{"environment": "test", "region": "northeurope", "replicas": "3"},
+			wantOrigins: map[string]config.Origin{
+				"region":   {Source: config.SourceEnvironment, Key: "GOTF_PARAM_REGION"},
+				"replicas": {Source: config.SourceParamsFile, File: "testdata/params/params.yaml", Key: "replicas"},
+			},
+		},
+		{
+			name:       "declared names ignore case",
+			configFile: "testdata/params/gotf.yaml",
+			envs:       map[string]string{"GOTF_PARAM_AWSREGION": "eu-central-1", "GOTF_PARAM_ENVIRONMENT": "prod", "GOTF_PARAM_OWNER": "me"},
+			want:       map[string]string{"awsRegion": "eu-central-1", "environment": "prod", "owner": "me"},
+			wantOrigins: map[string]config.Origin{
+				"awsRegion":   {Source: config.SourceEnvironment, Key: "GOTF_PARAM_AWSREGION"},
+				"environment": {Source: config.SourceEnvironment, Key: "GOTF_PARAM_ENVIRONMENT"},
+				"owner":       {Source: config.SourceEnvironment, Key: "GOTF_PARAM_OWNER"},
+			},
+		},
+		{
+			name:       "non-scalar value",
+			paramsFile: "testdata/params/invalid.yaml",
+			wantErr:    `could not read params file "testdata/params/invalid.yaml": value of param "environment" must be a scalar`,
+		},
+		{
+			name:       "missing file",
+			paramsFile: "testdata/params/missing.yaml",
+			wantErr:    `could not read params file "testdata/params/missing.yaml"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.envs {
+				t.Setenv(k, v)
+			}
+			got, gotOrigins, err := resolveParams(Args{ConfigFile: tt.configFile, ParamsFile: tt.paramsFile, Params: tt.cliParams})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOrigins, gotOrigins)
+		})
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/craftypath/gotf/pkg/config"
+)
+
+// plansDir is the directory below the module directory where saved plans are stored.
+var plansDir = filepath.Join(".gotf", "plans")
+
+var unsafePlanNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// savedPlan is a plan file managed by gotf. 'plan' writes it along with metadata describing
+// the params and config it was created with. 'apply' only uses it if these still match.
+type savedPlan struct {
+	command   string
+	moduleDir string
+	// file is the plan file relative to the module directory.
+	file     string
+	metadata planMetadata
+	logger   *log.Logger
+}
+
+type planMetadata struct {
+	Params     map[string]string `json:"params"`
+	ConfigHash string            `json:"configHash"`
+}
+
+// savedPlanArgs validates the Terraform args for use with saved plans. Vars are disabled for
+// 'apply' because Terraform takes them from the plan file.
+func savedPlanArgs(args Args) (Args, error) {
+	if len(args.Args) == 0 {
+		return args, nil
+	}
+	switch args.Args[0] {
+	case "plan":
+		for _, arg := range args.Args[1:] {
+			if arg == "-out" || strings.HasPrefix(arg, "-out=") {
+				return args, errors.New("-out must not be specified when using saved plans")
+			}
+		}
+	case "apply":
+		args.NoVars = true
+	default:
+		return args, fmt.Errorf("saved plans require 'plan' or 'apply' as Terraform command, got %q", args.Args[0])
+	}
+	return args, nil
+}
+
+// newSavedPlan returns the saved plan for the module. Its file name is derived from the params
+// specified for the run, e.g. '.gotf/plans/dev-<hash>.tfplan' for '-p environment=dev'. Params
+// from the config file are covered by the config hash instead.
+func newSavedPlan(args Args, cfg *config.Config) (*savedPlan, error) {
+	params := cfg.RunParams()
+	hash, err := configHash(cfg, args.ModuleDir)
+	if err != nil {
+		return nil, err
+	}
+	return &savedPlan{
+		command:   args.Args[0],
+		moduleDir: args.ModuleDir,
+		file:      filepath.Join(plansDir, planName(params)+".tfplan"),
+		metadata:  planMetadata{Params: params, ConfigHash: hash},
+		logger:    log.Default(),
+	}, nil
+}
+
+// terraformArgs adds the plan file to the Terraform args.
+func (p *savedPlan) terraformArgs(args []string) []string {
+	result := append([]string{}, args...)
+	if p.command == "plan" {
+		return append(result, "-out="+p.file)
+	}
+	return append(result, p.file)
+}
+
+// before prepares a new plan for 'plan' and checks that the saved plan matches for 'apply'.
+func (p *savedPlan) before() error {
+	if p.command == "plan" {
+		// a failed run must not leave a previous plan behind
+		if err := p.remove(); err != nil {
+			return err
+		}
+		return p.createPlansDir()
+	}
+
+	data, err := ioutil.ReadFile(p.path(p.metadataFile()))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no saved plan found at %s, run 'plan' first", p.path(p.file))
+		}
+		return err
+	}
+	var metadata planMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return fmt.Errorf("invalid metadata for saved plan %s: %w", p.path(p.file), err)
+	}
+	if !reflect.DeepEqual(metadata.Params, p.metadata.Params) {
+		return fmt.Errorf("saved plan %s was created with params %s, not %s", p.path(p.file),
+			describeParams(metadata.Params), describeParams(p.metadata.Params))
+	}
+	if metadata.ConfigHash != p.metadata.ConfigHash {
+		return fmt.Errorf("saved plan %s was created with a different config, run 'plan' again", p.path(p.file))
+	}
+	p.logger.Println("Using saved plan:", p.path(p.file))
+	return nil
+}
+
+// after writes the metadata of a new plan or removes an applied plan, which is stale then. The
+// error of the Terraform run is passed in and returned unless handling the plan fails.
+func (p *savedPlan) after(runErr error) error {
+	if p.command == "plan" {
+		// exit code 2 reports changes if '-detailed-exitcode' is specified
+		var exitErr *exec.ExitError
+		if runErr != nil && !(errors.As(runErr, &exitErr) && exitErr.ExitCode() == 2) {
+			return runErr
+		}
+		data, err := json.MarshalIndent(p.metadata, "", "  ")
+		if err != nil {
+			return err
+		}
+		p.logger.Println("Saved plan:", p.path(p.file))
+		if err := ioutil.WriteFile(p.path(p.metadataFile()), append(data, '\n'), 0644); err != nil {
+			return err
+		}
+		return runErr
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+	p.logger.Println("Removing applied plan:", p.path(p.file))
+	return p.remove()
+}
+
+func (p *savedPlan) remove() error {
+	for _, f := range []string{p.file, p.metadataFile()} {
+		if err := os.Remove(p.path(f)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// createPlansDir creates the plans directory. A '.gitignore' file keeps plans, which may
+// contain secrets, from being committed.
+func (p *savedPlan) createPlansDir() error {
+	dir := p.path(plansDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	gitignore := filepath.Join(filepath.Dir(dir), ".gitignore")
+	if _, err := os.Stat(gitignore); os.IsNotExist(err) {
+		return ioutil.WriteFile(gitignore, []byte("*\n"), 0644)
+	}
+	return nil
+}
+
+func (p *savedPlan) metadataFile() string {
+	return strings.TrimSuffix(p.file, ".tfplan") + ".json"
+}
+
+func (p *savedPlan) path(file string) string {
+	return filepath.Join(p.moduleDir, file)
+}
+
+// planName joins the param values in the order of their names. Since different params may
+// result in the same values after replacing unsafe characters or joining them, a short hash
+// of the params is appended.
+func planName(params map[string]string) string {
+	if len(params) == 0 {
+		return "default"
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		values = append(values, unsafePlanNameChars.ReplaceAllString(params[name], "_"))
+	}
+	// maps are marshalled with sorted keys, so the hash is deterministic
+	data, _ := json.Marshal(params)
+	sum := sha256.Sum256(data)
+	values = append(values, hex.EncodeToString(sum[:4]))
+	return strings.Join(values, "-")
+}
+
+func describeParams(params map[string]string) string {
+	if len(params) == 0 {
+		return "none"
+	}
+	return config.DescribeParams(params)
+}
+
+// configHash hashes the resolved config including the contents of var files, so that changes to
+// the config after planning are detected.
+func configHash(cfg *config.Config, moduleDir string) (string, error) {
+	varFiles := make([]string, 0, len(cfg.VarFiles))
+	for _, f := range cfg.VarFiles {
+		path := f
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(moduleDir, f)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("could not read var file: %w", err)
+		}
+		sum := sha256.Sum256(data)
+		varFiles = append(varFiles, f+":"+hex.EncodeToString(sum[:]))
+	}
+
+	// backend configs may contain nested YAML maps which cannot be marshalled as JSON
+	backendConfigs := make(map[string]string, len(cfg.BackendConfigs))
+	for k, v := range cfg.BackendConfigs {
+		backendConfigs[k] = fmt.Sprint(v)
+	}
+
+	// maps are marshalled with sorted keys, so the result is deterministic
+	data, err := json.Marshal(struct {
+		TerraformVersion string
+		Vars             map[string]string
+		VarFiles         []string
+		Envs             map[string]string
+		BackendConfigs   map[string]string
+	}{cfg.TerraformVersion, cfg.Vars, varFiles, cfg.Envs, backendConfigs})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,185 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/craftypath/gotf/pkg/config"
+	"github.com/craftypath/gotf/pkg/sh"
+	terraform "github.com/craftypath/gotf/pkg/tf"
+)
+
+type fakeShell struct {
+	workingDir string
+	env        map[string]string
+	args       []string
+	err        error
+}
+
+// Execute records the call and creates the plan file specified via '-out'. It returns err if set.
+func (s *fakeShell) Execute(env map[string]string, _ sh.Sensitive, workingDir string, _ string, args ...string) error {
+	s.workingDir = workingDir
+	s.env = env
+	s.args = args
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-out=") {
+			return ioutil.WriteFile(filepath.Join(workingDir, strings.TrimPrefix(arg, "-out=")), []byte("plan"), 0644)
+		}
+	}
+	return s.err
+}
+
+func TestSavedPlanArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantNoVars bool
+		wantErr    string
+	}{
+		{name: "plan", args: []string{"plan", "-lock=false"}},
+		{name: "apply", args: []string{"apply", "-auto-approve"}, wantNoVars: true},
+		{name: "plan with out", args: []string{"plan", "-out=my.tfplan"}, wantErr: "-out must not be specified when using saved plans"},
+		{name: "plan with separate out", args: []string{"plan", "-out", "my.tfplan"}, wantErr: "-out must not be specified when using saved plans"},
+		{name: "other command", args: []string{"destroy"}, wantErr: `saved plans require 'plan' or 'apply' as Terraform command, got "destroy"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := savedPlanArgs(Args{Args: tt.args})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNoVars, got.NoVars)
+		})
+	}
+}
+
+func TestPlanName(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   string
+	}{
+		{name: "no params", params: map[string]string{}, want: "default"},
+		{name: "single param", params: map[string]string{"environment": "dev"}, want: "dev-12caaf95"},
+		{name: "sorted by name", params: map[string]string{"region": "westeurope", "environment": "dev"}, want: "dev-westeurope-cae95162"},
+		{name: "unsafe chars", params: map[string]string{"environment": "dev/eu 1"}, want: "dev_eu_1-62011e24"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, planName(tt.params))
+		})
+	}
+}
+
+func TestPlanName_Unique(t *testing.T) {
+	tests := []struct {
+		name string
+		a    map[string]string
+		b    map[string]string
+	}{
+		{name: "unsafe chars", a: map[string]string{"environment": "dev/eu"}, b: map[string]string{"environment": "dev_eu"}},
+		{name: "separator in values", a: map[string]string{"a": "x-y", "b": "z"}, b: map[string]string{"a": "x", "b": "y-z"}},
+		{name: "different names", a: map[string]string{"environment": "dev"}, b: map[string]string{"stage": "dev"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NotEqual(t, planName(tt.a), planName(tt.b))
+		})
+	}
+}
+
+func TestExecute_SavedPlan(t *testing.T) {
+	moduleDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(moduleDir, "dev.tfvars"), []byte(`foo = "bar"`), 0644))
+
+	newConfig := func(environment string, varValue string) *config.Config {
+		return &config.Config{
+			Params:   map[string]interface{}{"environment": environment, "moduleDir": filepath.Base(moduleDir), "team": "a"},
+			Vars:     map[string]string{"var": varValue},
+			VarFiles: []string{"dev.tfvars"},
+			Envs:     map[string]string{"ENV": "env"},
+			Origins: config.Origins{Params: map[string]config.Origin{
+				"environment": {Source: config.SourceCLI},
+				"moduleDir":   {Source: config.SourceModuleDir},
+				"team":        {Source: config.SourceParams},
+			}},
+		}
+	}
+	run := func(cfg *config.Config, tfArgs ...string) (*fakeShell, error) {
+		args, err := savedPlanArgs(Args{ModuleDir: moduleDir, Args: tfArgs})
+		require.NoError(t, err)
+		plan, err := newSavedPlan(args, cfg)
+		require.NoError(t, err)
+		shell := &fakeShell{}
+		tf := terraform.NewTerraform(cfg, moduleDir, nil, true, args.NoVars, shell, "terraform")
+		return shell, execute(tf, plan, args.Args)
+	}
+	planFile := filepath.Join(".gotf", "plans", "dev-12caaf95.tfplan")
+
+	shell, err := run(newConfig("dev", "value"), "plan")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"plan", "-out=" + planFile}, shell.args)
+	assert.FileExists(t, filepath.Join(moduleDir, planFile))
+	assert.FileExists(t, filepath.Join(moduleDir, ".gotf", "plans", "dev-12caaf95.json"))
+	assert.FileExists(t, filepath.Join(moduleDir, ".gotf", ".gitignore"))
+
+	_, err = run(newConfig("prod", "value"), "apply")
+	assert.EqualError(t, err, "no saved plan found at "+filepath.Join(moduleDir, ".gotf", "plans", "prod-95ce8741.tfplan")+", run 'plan' first")
+
+	_, err = run(newConfig("dev", "changed"), "apply")
+	assert.EqualError(t, err, "saved plan "+filepath.Join(moduleDir, planFile)+" was created with a different config, run 'plan' again")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(moduleDir, "dev.tfvars"), []byte(`foo = "baz"`), 0644))
+	_, err = run(newConfig("dev", "value"), "apply")
+	assert.EqualError(t, err, "saved plan "+filepath.Join(moduleDir, planFile)+" was created with a different config, run 'plan' again")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(moduleDir, "dev.tfvars"), []byte(`foo = "bar"`), 0644))
+
+	shell, err = run(newConfig("dev", "value"), "apply", "-auto-approve")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"apply", "-auto-approve", planFile}, shell.args)
+	assert.Equal(t, map[string]string{"ENV": "env"}, shell.env)
+	_, err = os.Stat(filepath.Join(moduleDir, planFile))
+	assert.True(t, os.IsNotExist(err), "applied plan must be removed")
+}
+
+func TestSavedPlan_ParamsMismatch(t *testing.T) {
+	moduleDir := t.TempDir()
+	plan := &savedPlan{
+		command:   "plan",
+		moduleDir: moduleDir,
+		file:      filepath.Join(plansDir, "dev.tfplan"),
+		metadata:  planMetadata{Params: map[string]string{"environment": "dev", "region": "eu"}, ConfigHash: "hash"},
+		logger:    log.Default(),
+	}
+	require.NoError(t, plan.before())
+	require.NoError(t, plan.after(nil))
+
+	plan.command = "apply"
+	plan.metadata.Params = map[string]string{"environment": "dev"}
+	err := plan.before()
+	assert.EqualError(t, err, "saved plan "+filepath.Join(moduleDir, plansDir, "dev.tfplan")+
+		" was created with params environment=dev, region=eu, not environment=dev")
+}
@@ -0,0 +1,77 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/craftypath/gotf/pkg/config"
+)
+
+// isInteractive reports whether params may be prompted for. It is a variable so tests can override it.
+var isInteractive = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// prompt asks for the value of the param until a valid value is entered. If the param has
+// allowed values, they can be selected by number.
+func (p *prompter) prompt(param config.MissingParam) (string, error) {
+	fmt.Fprintf(p.out, "Required parameter %q is missing.", param.Name)
+	if param.Description != "" {
+		fmt.Fprintf(p.out, " %s", param.Description)
+	}
+	fmt.Fprintln(p.out)
+	for i, v := range param.Values {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, v)
+	}
+
+	for {
+		if len(param.Values) > 0 {
+			fmt.Fprintf(p.out, "Select %s [1-%d]: ", param.Name, len(param.Values))
+		} else {
+			fmt.Fprintf(p.out, "Enter %s: ", param.Name)
+		}
+
+		line, err := p.in.ReadString('\n')
+		value := strings.TrimSpace(line)
+		if err != nil && (err != io.EOF || value == "") {
+			return "", err
+		}
+
+		if n, convErr := strconv.Atoi(value); convErr == nil && n >= 1 && n <= len(param.Values) {
+			value = param.Values[n-1]
+		}
+		if value == "" {
+			continue
+		}
+		if checkErr := param.Check(value); checkErr != nil {
+			fmt.Fprintf(p.out, "Invalid value %q: %v\n", value, checkErr)
+			continue
+		}
+		return value, nil
+	}
+}
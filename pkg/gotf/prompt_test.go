@@ -0,0 +1,107 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/craftypath/gotf/pkg/config"
+)
+
+func TestPrompter_Prompt(t *testing.T) {
+	environment := config.MissingParam{
+		Name:      "environment",
+		ParamSpec: config.ParamSpec{Description: "Target environment", Values: []string{"dev", "prod"}},
+	}
+	feature := config.MissingParam{
+		Name:      "feature",
+		ParamSpec: config.ParamSpec{Pattern: "feature-[a-z]+"},
+	}
+
+	tests := []struct {
+		name       string
+		param      config.MissingParam
+		input      string
+		want       string
+		wantOutput string
+		wantErr    bool
+	}{
+		{
+			name:  "select by number",
+			param: environment,
+			input: "2\n",
+			want:  "prod",
+			wantOutput: `Required parameter "environment" is missing. Target environment
+  1) dev
+  2) prod
+Select environment [1-2]: `,
+		},
+		{
+			name:  "select by value after invalid input",
+			param: environment,
+			input: "3\n\ndev",
+			want:  "dev",
+			wantOutput: `Required parameter "environment" is missing. Target environment
+  1) dev
+  2) prod
+Select environment [1-2]: Invalid value "3": must be one of [dev prod]
+Select environment [1-2]: Select environment [1-2]: `,
+		},
+		{
+			name:  "free-form value matching pattern",
+			param: feature,
+			input: "foo\nfeature-login\n",
+			want:  "feature-login",
+			wantOutput: `Required parameter "feature" is missing.
+Enter feature: Invalid value "foo": must match pattern "feature-[a-z]+"
+Enter feature: `,
+		},
+		{
+			name:    "end of input",
+			param:   feature,
+			input:   "foo\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			p := &prompter{in: bufio.NewReader(strings.NewReader(tt.input)), out: &out}
+			got, err := p.prompt(tt.param)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOutput, out.String())
+		})
+	}
+}
+
+func TestLoadConfig_NoInput(t *testing.T) {
+	orig := isInteractive
+	isInteractive = func() bool { return true }
+	defer func() { isInteractive = orig }()
+
+	_, err := loadConfig(Args{ConfigFile: "../config/testdata/test-config.yaml", ModuleDir: ".", Params: map[string]string{}, NoInput: true})
+	require.Error(t, err)
+	assert.Equal(t, `could not load config file "../config/testdata/test-config.yaml": required parameter "environment" must be specified`, err.Error())
+}
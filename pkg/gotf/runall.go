@@ -0,0 +1,536 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/craftypath/gotf/pkg/config"
+	"github.com/craftypath/gotf/pkg/sh"
+	terraform "github.com/craftypath/gotf/pkg/tf"
+)
+
+type RunAllArgs struct {
+	Args
+	// Modules lists module directories or glob patterns. If empty, modules are
+	// discovered recursively below Args.ModuleDir.
+	Modules []string
+	// Parallelism limits the number of modules run concurrently. Output of
+	// concurrently running modules is prefixed with the module name.
+	Parallelism int
+	// KeepGoing continues running modules not depending on a failed module. Otherwise, no
+	// further modules are started after the first failure.
+	KeepGoing bool
+}
+
+type moduleStatus int
+
+const (
+	statusPending moduleStatus = iota
+	statusSucceeded
+	statusFailed
+	statusSkipped
+)
+
+func (s moduleStatus) String() string {
+	switch s {
+	case statusSucceeded:
+		return "succeeded"
+	case statusFailed:
+		return "failed"
+	case statusSkipped:
+		return "skipped"
+	default:
+		return "pending"
+	}
+}
+
+type module struct {
+	name      string
+	dir       string
+	tf        *terraform.Terraform
+	plan      *savedPlan
+	output    []*sh.PrefixWriter
+	dependsOn []*module
+	status    moduleStatus
+	err       error
+}
+
+func (m *module) run(args []string) {
+	err := execute(m.tf, m.plan, args)
+	for _, w := range m.output {
+		if flushErr := w.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+	if err != nil {
+		m.status = statusFailed
+		m.err = err
+		return
+	}
+	m.status = statusSucceeded
+}
+
+// failedDependency returns the first dependency that did not succeed.
+func (m *module) failedDependency() *module {
+	for _, dep := range m.dependsOn {
+		if dep.status != statusSucceeded {
+			return dep
+		}
+	}
+	return nil
+}
+
+var modulePrefixRegex = regexp.MustCompile(`^(\d+)[_-]`)
+
+func RunAll(args RunAllArgs) error {
+	if len(args.Args.Args) == 0 {
+		return errors.New("no arguments for Terraform specified")
+	}
+	if args.Parallelism < 1 {
+		args.Parallelism = 1
+	}
+
+	setupLogging(args.Debug)
+
+	if args.SavedPlan {
+		var err error
+		if args.Args, err = savedPlanArgs(args.Args); err != nil {
+			return err
+		}
+	}
+
+	moduleDirs, err := findModules(args.ModuleDir, args.Modules)
+	if err != nil {
+		return err
+	}
+	if len(moduleDirs) == 0 {
+		return errors.New("no modules found")
+	}
+
+	// dependsOn is read once from the config file rather than from each module's config
+	dependsOn, err := config.LoadDependsOn(args.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("could not load config file %q: %w", args.ConfigFile, err)
+	}
+
+	var outputMu sync.Mutex
+	modules := make([]*module, 0, len(moduleDirs))
+	names := make(map[string]string, len(moduleDirs))
+	for _, dir := range moduleDirs {
+		// modules are named by their path relative to the config file, so modules with the
+		// same directory name in different parent directories can be told apart
+		name, err := config.ModulePath(args.ConfigFile, dir)
+		if err != nil {
+			return err
+		}
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("module directories %s and %s are the same module", other, dir)
+		}
+		names[name] = dir
+
+		m := &module{name: name, dir: dir}
+		shell := sh.Shell{}
+		var logger *log.Logger
+		if args.Parallelism > 1 {
+			stdout := sh.NewPrefixWriter(os.Stdout, "["+name+"] ", &outputMu)
+			stderr := sh.NewPrefixWriter(os.Stderr, "["+name+"] ", &outputMu)
+			m.output = []*sh.PrefixWriter{stdout, stderr}
+			logger = newLogger(args.Debug, stderr)
+			// concurrently running modules must not read from stdin
+			shell = sh.Shell{Stdin: strings.NewReader(""), Stdout: stdout, Stderr: stderr, Logger: logger}
+		}
+
+		moduleArgs := args.Args
+		moduleArgs.ModuleDir = dir
+		cfg, tf, err := prepare(moduleArgs, shell)
+		if err != nil {
+			return fmt.Errorf("module %s: %w", dir, err)
+		}
+		m.tf = tf
+		if moduleArgs.SavedPlan {
+			if m.plan, err = newSavedPlan(moduleArgs, cfg); err != nil {
+				return fmt.Errorf("module %s: %w", dir, err)
+			}
+		}
+		if logger != nil {
+			m.tf.SetLogger(logger)
+			if m.plan != nil {
+				m.plan.logger = logger
+			}
+		}
+		modules = append(modules, m)
+	}
+
+	levels, err := orderModules(modules, dependsOn)
+	if err != nil {
+		return err
+	}
+	if isDestroy(args.Args.Args) {
+		log.Println("Reversing module order for destroy")
+		levels = reverseOrder(levels)
+	}
+
+	runModules(levels, args.Parallelism, args.KeepGoing, args.Args.Args)
+	return summarize(levels)
+}
+
+// runModules runs the modules level by level. Modules are skipped if a module they depend on
+// did not succeed or, unless keepGoing is set, if any module failed before.
+func runModules(levels [][]*module, parallelism int, keepGoing bool, args []string) {
+	var (
+		failedMu sync.Mutex
+		failed   *module
+	)
+	firstFailure := func() *module {
+		failedMu.Lock()
+		defer failedMu.Unlock()
+		return failed
+	}
+
+	for _, level := range levels {
+		sem := make(chan struct{}, parallelism)
+		var wg sync.WaitGroup
+		for _, m := range level {
+			if dep := m.failedDependency(); dep != nil {
+				m.status = statusSkipped
+				m.err = fmt.Errorf("dependency %s %s", dep.name, dep.status)
+				continue
+			}
+			sem <- struct{}{}
+			if f := firstFailure(); f != nil && !keepGoing {
+				<-sem
+				m.status = statusSkipped
+				m.err = fmt.Errorf("run stopped after module %s failed", f.name)
+				continue
+			}
+			wg.Add(1)
+			if parallelism == 1 {
+				fmt.Fprintf(os.Stderr, "==> Module %s\n", m.dir)
+			}
+			go func(m *module) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				m.run(args)
+				if m.status == statusFailed {
+					failedMu.Lock()
+					if failed == nil {
+						failed = m
+					}
+					failedMu.Unlock()
+				}
+			}(m)
+		}
+		wg.Wait()
+	}
+}
+
+// newLogger returns a logger for debug output of a module, which is discarded unless debug is
+// enabled.
+func newLogger(debug bool, w io.Writer) *log.Logger {
+	if !debug {
+		w = ioutil.Discard
+	}
+	return log.New(w, log.Prefix(), log.Flags())
+}
+
+// summarize prints the status of all modules to stderr and returns an error if not all succeeded.
+func summarize(levels [][]*module) error {
+	var failed int
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Summary:")
+	for _, level := range levels {
+		for _, m := range level {
+			if m.err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "  %s: %s (%v)\n", m.dir, m.status, m.err)
+			} else {
+				fmt.Fprintf(os.Stderr, "  %s: %s\n", m.dir, m.status)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d module(s) did not succeed", failed)
+	}
+	return nil
+}
+
+// findModules returns the specified module directories with glob patterns expanded. If none
+// are specified, all directories below rootDir containing '.tf' files are returned.
+// Hidden directories and directories below module directories are skipped.
+func findModules(rootDir string, patterns []string) ([]string, error) {
+	var dirs []string
+	if len(patterns) > 0 {
+		for _, p := range patterns {
+			matches, err := filepath.Glob(p)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no module directory found matching %q", p)
+			}
+			for _, m := range matches {
+				if fi, err := os.Stat(m); err == nil && fi.IsDir() {
+					dirs = append(dirs, m)
+				}
+			}
+		}
+		return dirs, nil
+	}
+
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || path == rootDir {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		tfFiles, err := filepath.Glob(filepath.Join(path, "*.tf"))
+		if err != nil {
+			return err
+		}
+		if len(tfFiles) > 0 {
+			dirs = append(dirs, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// orderModules groups modules into levels which must be run one after another. Modules in
+// the same level don't depend on each other. If dependsOn is configured, levels are computed
+// from the dependency graph. Otherwise, modules are grouped by the numeric prefix of their
+// directory name, e.g. '01_networking', with modules without prefix coming last.
+func orderModules(modules []*module, dependsOn map[string][]string) ([][]*module, error) {
+	dependsOn, err := resolveDependsOn(modules, dependsOn)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]*module, len(modules))
+	copy(sorted, modules)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].name < sorted[j].name
+	})
+
+	var levelOf func(m *module) (int, error)
+	if len(dependsOn) > 0 {
+		byName := make(map[string]*module, len(sorted))
+		for _, m := range sorted {
+			byName[m.name] = m
+		}
+		levels := make(map[string]int)
+		var visiting []string
+		levelOf = func(m *module) (int, error) {
+			if l, ok := levels[m.name]; ok {
+				return l, nil
+			}
+			for i, v := range visiting {
+				if v == m.name {
+					return 0, fmt.Errorf("module dependency cycle detected: %s", strings.Join(append(visiting[i:], m.name), " -> "))
+				}
+			}
+			visiting = append(visiting, m.name)
+			level := 0
+			for _, depName := range dependsOn[m.name] {
+				dep, ok := byName[depName]
+				if !ok {
+					log.Printf("Dependency %s of module %s is not part of this run. Ignoring it.\n", depName, m.name)
+					continue
+				}
+				depLevel, err := levelOf(dep)
+				if err != nil {
+					return 0, err
+				}
+				if depLevel+1 > level {
+					level = depLevel + 1
+				}
+			}
+			visiting = visiting[:len(visiting)-1]
+			levels[m.name] = level
+			return level, nil
+		}
+	} else {
+		prefixes := make(map[int]bool)
+		for _, m := range sorted {
+			if p, ok := modulePrefix(path.Base(m.name)); ok {
+				prefixes[p] = true
+			}
+		}
+		ranks := make([]int, 0, len(prefixes))
+		for p := range prefixes {
+			ranks = append(ranks, p)
+		}
+		sort.Ints(ranks)
+		levelOf = func(m *module) (int, error) {
+			p, ok := modulePrefix(path.Base(m.name))
+			if !ok {
+				return len(ranks), nil
+			}
+			return sort.SearchInts(ranks, p), nil
+		}
+	}
+
+	var result [][]*module
+	for _, m := range sorted {
+		level, err := levelOf(m)
+		if err != nil {
+			return nil, err
+		}
+		for len(result) <= level {
+			result = append(result, nil)
+		}
+		result[level] = append(result[level], m)
+	}
+
+	// drop empty levels
+	levels := result[:0]
+	for _, l := range result {
+		if len(l) > 0 {
+			levels = append(levels, l)
+		}
+	}
+
+	// Without explicit dependencies, modules depend on all modules of the previous level.
+	// With explicit dependencies, only those which are part of this run are considered.
+	for i, level := range levels {
+		for _, m := range level {
+			m.dependsOn = nil
+			if len(dependsOn) == 0 {
+				if i > 0 {
+					m.dependsOn = append(m.dependsOn, levels[i-1]...)
+				}
+				continue
+			}
+			for _, depName := range dependsOn[m.name] {
+				for _, dep := range sorted {
+					if dep.name == depName {
+						m.dependsOn = append(m.dependsOn, dep)
+					}
+				}
+			}
+		}
+	}
+	return levels, nil
+}
+
+// resolveDependsOn returns dependsOn keyed by module names, i.e. module paths relative to the
+// config file. Modules may also be referred to by their directory name as long as it is
+// unambiguous. Names not matching any module are kept as they are.
+func resolveDependsOn(modules []*module, dependsOn map[string][]string) (map[string][]string, error) {
+	if len(dependsOn) == 0 {
+		return dependsOn, nil
+	}
+
+	byName := make(map[string]string, len(modules))
+	byDirName := make(map[string][]string)
+	for _, m := range modules {
+		byName[m.name] = m.name
+		dirName := path.Base(m.name)
+		byDirName[dirName] = append(byDirName[dirName], m.name)
+	}
+	resolve := func(name string) (string, error) {
+		if n, ok := byName[name]; ok {
+			return n, nil
+		}
+		names := byDirName[name]
+		switch {
+		case strings.Contains(name, "/") || len(names) == 0:
+			return name, nil
+		case len(names) > 1:
+			sort.Strings(names)
+			return "", fmt.Errorf("dependsOn: module name %q is ambiguous, use the module path instead: %s", name, strings.Join(names, ", "))
+		default:
+			return names[0], nil
+		}
+	}
+
+	resolved := make(map[string][]string, len(dependsOn))
+	for name, deps := range dependsOn {
+		key, err := resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range deps {
+			d, err := resolve(dep)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = append(resolved[key], d)
+		}
+	}
+	return resolved, nil
+}
+
+// reverseOrder reverses the order of levels and inverts the dependencies between modules.
+func reverseOrder(levels [][]*module) [][]*module {
+	dependents := make(map[*module][]*module)
+	for _, level := range levels {
+		for _, m := range level {
+			for _, dep := range m.dependsOn {
+				dependents[dep] = append(dependents[dep], m)
+			}
+		}
+	}
+
+	reversed := make([][]*module, 0, len(levels))
+	for i := len(levels) - 1; i >= 0; i-- {
+		for _, m := range levels[i] {
+			m.dependsOn = dependents[m]
+		}
+		reversed = append(reversed, levels[i])
+	}
+	return reversed
+}
+
+func modulePrefix(name string) (int, bool) {
+	m := modulePrefixRegex.FindStringSubmatch(name)
+	if m == nil {
+		return 0, false
+	}
+	p, err := strconv.Atoi(m[1])
+	return p, err == nil
+}
+
+func isDestroy(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if args[0] == "destroy" {
+		return true
+	}
+	for _, arg := range args[1:] {
+		if arg == "-destroy" {
+			return true
+		}
+	}
+	return false
+}
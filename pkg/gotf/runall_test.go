@@ -0,0 +1,209 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/craftypath/gotf/pkg/config"
+	terraform "github.com/craftypath/gotf/pkg/tf"
+)
+
+func TestOrderModules(t *testing.T) {
+	tests := []struct {
+		name       string
+		modules    []string
+		dependsOn  map[string][]string
+		want       [][]string
+		wantErrMsg string
+	}{
+		{
+			name:    "by name prefix",
+			modules: []string{"shared", "02_compute", "01_networking", "02_database", "10_app"},
+			want: [][]string{
+				{"01_networking"},
+				{"02_compute", "02_database"},
+				{"10_app"},
+				{"shared"},
+			},
+		},
+		{
+			name:    "by dependsOn",
+			modules: []string{"network", "compute", "database", "app"},
+			dependsOn: map[string][]string{
+				"compute":  {"network"},
+				"database": {"network"},
+				"app":      {"compute", "database", "unknown"},
+			},
+			want: [][]string{
+				{"network"},
+				{"compute", "database"},
+				{"app"},
+			},
+		},
+		{
+			name:    "by name prefix with module paths",
+			modules: []string{"aws/02_compute", "azure/01_network", "aws/01_network"},
+			want: [][]string{
+				{"aws/01_network", "azure/01_network"},
+				{"aws/02_compute"},
+			},
+		},
+		{
+			name:    "by dependsOn with module paths",
+			modules: []string{"aws/network", "azure/network", "aws/compute", "azure/compute"},
+			dependsOn: map[string][]string{
+				"aws/compute":   {"aws/network"},
+				"azure/compute": {"azure/network", "aws/compute"},
+			},
+			want: [][]string{
+				{"aws/network", "azure/network"},
+				{"aws/compute"},
+				{"azure/compute"},
+			},
+		},
+		{
+			name:    "by dependsOn with unambiguous directory names",
+			modules: []string{"aws/network", "aws/compute", "azure/app"},
+			dependsOn: map[string][]string{
+				"compute": {"network"},
+				"app":     {"aws/compute"},
+			},
+			want: [][]string{
+				{"aws/network"},
+				{"aws/compute"},
+				{"azure/app"},
+			},
+		},
+		{
+			name:    "by dependsOn with ambiguous directory name",
+			modules: []string{"aws/network", "azure/network", "aws/compute"},
+			dependsOn: map[string][]string{
+				"compute": {"network"},
+			},
+			wantErrMsg: `dependsOn: module name "network" is ambiguous, use the module path instead: aws/network, azure/network`,
+		},
+		{
+			name:    "dependency cycle",
+			modules: []string{"a", "b", "c"},
+			dependsOn: map[string][]string{
+				"a": {"b"},
+				"b": {"c"},
+				"c": {"a"},
+			},
+			wantErrMsg: "module dependency cycle detected: a -> b -> c -> a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var modules []*module
+			for _, name := range tt.modules {
+				modules = append(modules, &module{name: name, dir: name})
+			}
+
+			got, err := orderModules(modules, tt.dependsOn)
+			if tt.wantErrMsg != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+			require.NoError(t, err)
+
+			var gotNames [][]string
+			for _, level := range got {
+				var names []string
+				for _, m := range level {
+					names = append(names, m.name)
+				}
+				gotNames = append(gotNames, names)
+			}
+			assert.Equal(t, tt.want, gotNames)
+		})
+	}
+}
+
+func TestReverseOrder(t *testing.T) {
+	network := &module{name: "network"}
+	compute := &module{name: "compute", dependsOn: []*module{network}}
+	database := &module{name: "database", dependsOn: []*module{network}}
+	app := &module{name: "app", dependsOn: []*module{compute, database}}
+
+	got := reverseOrder([][]*module{{network}, {compute, database}, {app}})
+
+	assert.Equal(t, [][]*module{{app}, {compute, database}, {network}}, got)
+	assert.Empty(t, app.dependsOn)
+	assert.Equal(t, []*module{app}, compute.dependsOn)
+	assert.Equal(t, []*module{app}, database.dependsOn)
+	assert.Equal(t, []*module{compute, database}, network.dependsOn)
+}
+
+func TestRunModules(t *testing.T) {
+	tests := []struct {
+		name        string
+		parallelism int
+		keepGoing   bool
+		want        map[string]string
+	}{
+		{
+			name:        "sequential",
+			parallelism: 1,
+			want: map[string]string{
+				"a": "failed", "b": "skipped (run stopped after module a failed)",
+				"c": "skipped (dependency b skipped)", "d": "skipped (dependency a failed)",
+			},
+		},
+		{
+			name:        "keep going",
+			parallelism: 1,
+			keepGoing:   true,
+			want:        map[string]string{"a": "failed", "b": "succeeded", "c": "succeeded", "d": "skipped (dependency a failed)"},
+		},
+		{
+			name:        "parallel keep going",
+			parallelism: 2,
+			keepGoing:   true,
+			want:        map[string]string{"a": "failed", "b": "succeeded", "c": "succeeded", "d": "skipped (dependency a failed)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newModule := func(name string, err error) *module {
+				tf := terraform.NewTerraform(&config.Config{}, t.TempDir(), nil, true, true, &fakeShell{err: err}, "terraform")
+				return &module{name: name, dir: name, tf: tf}
+			}
+			a := newModule("a", errors.New("boom"))
+			b := newModule("b", nil)
+			c := newModule("c", nil)
+			d := newModule("d", nil)
+			c.dependsOn = []*module{b}
+			d.dependsOn = []*module{a}
+
+			runModules([][]*module{{a, b}, {c, d}}, tt.parallelism, tt.keepGoing, []string{"plan"})
+
+			got := make(map[string]string)
+			for _, m := range []*module{a, b, c, d} {
+				got[m.name] = m.status.String()
+				if m.status == statusSkipped {
+					got[m.name] += " (" + m.err.Error() + ")"
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/craftypath/gotf/pkg/config"
+	"github.com/craftypath/gotf/pkg/sh"
+	terraform "github.com/craftypath/gotf/pkg/tf"
+)
+
+const (
+	OutputYAML = "yaml"
+	OutputJSON = "json"
+)
+
+type ShowConfigArgs struct {
+	Args
+	// Output is the output format, 'yaml' or 'json'.
+	Output string
+	// Annotate adds the origin to each value.
+	Annotate bool
+}
+
+// resolvedConfig is the printable form of config.Config.
+type resolvedConfig struct {
+	TerraformVersion string                 `json:"terraformVersion,omitempty" yaml:"terraformVersion,omitempty"`
+	Params           map[string]interface{} `json:"params" yaml:"params"`
+	VarFiles         []string               `json:"varFiles" yaml:"varFiles"`
+	Vars             map[string]interface{} `json:"vars" yaml:"vars"`
+	Envs             map[string]interface{} `json:"envs" yaml:"envs"`
+	BackendConfigs   map[string]interface{} `json:"backendConfigs" yaml:"backendConfigs"`
+}
+
+// annotatedValue is a value along with its origin.
+type annotatedValue struct {
+	Value         interface{} `json:"value" yaml:"value"`
+	config.Origin `yaml:",inline"`
+	// Shadows lists overridden definitions of vars in precedence order.
+	Shadows []config.Definition `json:"shadows,omitempty" yaml:"shadows,omitempty"`
+}
+
+// ShowConfig writes the resolved config for the module to w. Sensitive values are masked.
+func ShowConfig(args ShowConfigArgs, w io.Writer) error {
+	if args.Output == "" {
+		args.Output = OutputYAML
+	}
+	if args.Output != OutputYAML && args.Output != OutputJSON {
+		return fmt.Errorf("unsupported output format %q: must be one of [%s %s]", args.Output, OutputYAML, OutputJSON)
+	}
+
+	setupLogging(args.Debug)
+
+	cfg, err := loadConfig(args.Args)
+	if err != nil {
+		return err
+	}
+	versionConstraint, err := terraformVersion(cfg, args.Args)
+	if err != nil {
+		return err
+	}
+
+	resolved := newResolvedConfig(cfg, versionConstraint, args.Annotate)
+	switch args.Output {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(resolved)
+	default:
+		out, err := yaml.Marshal(resolved)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	}
+}
+
+func newResolvedConfig(cfg *config.Config, terraformVersion string, annotate bool) resolvedConfig {
+	mask := newValueMasker(cfg)
+	value := func(v interface{}, sensitive bool, origin config.Origin) interface{} {
+		if annotate {
+			return annotatedValue{Value: mask(v, sensitive), Origin: origin}
+		}
+		return mask(v, sensitive)
+	}
+
+	resolved := resolvedConfig{
+		TerraformVersion: terraformVersion,
+		Params:           make(map[string]interface{}, len(cfg.Params)),
+		VarFiles:         cfg.VarFiles,
+		Vars:             make(map[string]interface{}, len(cfg.Vars)),
+		Envs:             make(map[string]interface{}, len(cfg.Envs)),
+		BackendConfigs:   make(map[string]interface{}, len(cfg.BackendConfigs)),
+	}
+	for k, v := range cfg.Params {
+		resolved.Params[k] = value(v, false, cfg.Origins.Params[k])
+	}
+	for k, v := range cfg.Vars {
+		sensitive := cfg.IsSensitiveVar(k)
+		resolved.Vars[k] = value(v, sensitive, cfg.Origins.Vars[k])
+		if annotated, ok := resolved.Vars[k].(annotatedValue); ok {
+			annotated.Shadows = maskDefinitions(cfg.Origins.ShadowedVars[k], sensitive, mask)
+			resolved.Vars[k] = annotated
+		}
+	}
+	for k, v := range cfg.Envs {
+		resolved.Envs[k] = value(v, cfg.IsSensitiveEnv(k), cfg.Origins.Envs[k])
+	}
+	for k, v := range cfg.BackendConfigs {
+		resolved.BackendConfigs[k] = value(v, cfg.IsSensitiveBackendConfig(k), cfg.Origins.BackendConfigs[k])
+	}
+	return resolved
+}
+
+// ExplainVars writes all definitions of the specified vars in precedence order to w. The
+// effective definition is marked with '*'. Definitions in var files are included because
+// Terraform gives them precedence over vars passed by gotf. If no vars are specified, all vars
+// are explained.
+func ExplainVars(args Args, vars []string, w io.Writer) error {
+	setupLogging(args.Debug)
+
+	cfg, err := loadConfig(args)
+	if err != nil {
+		return err
+	}
+	above, below, err := varFileDefinitions(cfg, args.ModuleDir)
+	if err != nil {
+		return err
+	}
+
+	if len(vars) == 0 {
+		names := make(map[string]bool)
+		for _, defs := range []map[string][]config.Definition{above, below} {
+			for k := range defs {
+				names[k] = true
+			}
+		}
+		for k := range cfg.Vars {
+			names[k] = true
+		}
+		for k := range names {
+			vars = append(vars, k)
+		}
+		sort.Strings(vars)
+	}
+
+	mask := newValueMasker(cfg)
+	for _, name := range vars {
+		defs := append([]config.Definition{}, above[name]...)
+		if value, ok := cfg.Vars[name]; ok {
+			defs = append(defs, config.Definition{Origin: cfg.Origins.Vars[name], Value: value, Evaluated: true})
+			defs = append(defs, cfg.Origins.ShadowedVars[name]...)
+		}
+		defs = append(defs, below[name]...)
+		if len(defs) == 0 {
+			return fmt.Errorf("var %q is not set", name)
+		}
+
+		fmt.Fprintln(w, name)
+		for i, d := range maskDefinitions(defs, cfg.IsSensitiveVar(name), mask) {
+			if i == 0 {
+				fmt.Fprintf(w, "  * %s\n", describeDefinition(d))
+			} else {
+				fmt.Fprintf(w, "    %s\n", describeDefinition(d))
+			}
+		}
+	}
+	return nil
+}
+
+// varFileDefinitions returns the definitions of vars in var files which take precedence over
+// vars passed by gotf and those which don't, each in precedence order. Var files passed via
+// '-var-file' override all vars passed by gotf. Var files loaded automatically by Terraform
+// override 'TF_VAR_' environment variables but not vars passed via a var file.
+func varFileDefinitions(cfg *config.Config, moduleDir string) (map[string][]config.Definition, map[string][]config.Definition, error) {
+	above := make(map[string][]config.Definition)
+	below := make(map[string][]config.Definition)
+	prepend := func(defs map[string][]config.Definition, path string, source string) error {
+		fileDefs, err := terraform.VarFileDefinitions(path, source)
+		if err != nil {
+			return fmt.Errorf("could not read var file %s: %w", path, err)
+		}
+		for k, d := range fileDefs {
+			defs[k] = append([]config.Definition{d}, defs[k]...)
+		}
+		return nil
+	}
+
+	autoVarFiles, err := terraform.AutoVarFiles(moduleDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	autoDefs := above
+	if cfg.VarsMode == config.VarsModeFile {
+		autoDefs = below
+	}
+	for _, f := range autoVarFiles {
+		if err := prepend(autoDefs, f, config.SourceAutoVarFiles); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, f := range cfg.VarFiles {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(moduleDir, f)
+		}
+		if _, err := os.Stat(f); os.IsNotExist(err) {
+			log.Println("Ignoring missing var file:", f)
+			continue
+		}
+		if err := prepend(above, f, config.SourceVarFiles); err != nil {
+			return nil, nil, err
+		}
+	}
+	return above, below, nil
+}
+
+func describeDefinition(d config.Definition) string {
+	s := d.Origin.String()
+	if d.Evaluated {
+		s += fmt.Sprintf(": %q", d.Value)
+	} else {
+		s += ": (not evaluated)"
+	}
+	if d.Template != "" {
+		s += fmt.Sprintf(" (template %q)", d.Template)
+	}
+	return s
+}
+
+// newValueMasker returns a function masking sensitive values completely and secret values
+// wherever they occur.
+func newValueMasker(cfg *config.Config) func(v interface{}, sensitive bool) string {
+	mask := sh.NewMasker(cfg.SensitiveValues)
+	return func(v interface{}, sensitive bool) string {
+		if sensitive {
+			return sh.MaskedValue
+		}
+		return mask(fmt.Sprint(v))
+	}
+}
+
+func maskDefinitions(defs []config.Definition, sensitive bool, mask func(v interface{}, sensitive bool) string) []config.Definition {
+	var result []config.Definition
+	for _, d := range defs {
+		if d.Evaluated {
+			d.Value = mask(d.Value, sensitive)
+		}
+		result = append(result, d)
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gotf
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/craftypath/gotf/pkg/config"
+)
+
+type ValidateArgs struct {
+	Args
+	// ConfigFiles are the config files to validate.
+	ConfigFiles []string
+	// Exhaustive additionally loads the config for each module and each combination of allowed
+	// values of required params.
+	Exhaustive bool
+	// Modules lists module directories or glob patterns used with Exhaustive. If empty, modules
+	// are discovered recursively below Args.ModuleDir.
+	Modules []string
+}
+
+// ValidateConfigs validates the config files and writes the result for each of them to w.
+func ValidateConfigs(args ValidateArgs, w io.Writer) error {
+	setupLogging(args.Debug)
+
+	var (
+		moduleDirs []string
+		params     map[string]string
+	)
+	if args.Exhaustive {
+		var err error
+		if params, _, err = resolveParams(args.Args); err != nil {
+			return err
+		}
+		if moduleDirs, err = findModules(args.ModuleDir, args.Modules); err != nil {
+			return err
+		}
+		if len(moduleDirs) == 0 {
+			moduleDirs = []string{args.ModuleDir}
+		}
+	}
+
+	var invalid int
+	for _, f := range args.ConfigFiles {
+		err := config.Validate(f)
+		if err == nil && args.Exhaustive {
+			var checked int
+			if checked, err = config.ValidateCombinations(f, moduleDirs, params); err == nil {
+				fmt.Fprintf(w, "%s: valid (%d combination(s) of modules and params checked)\n", f, checked)
+				continue
+			}
+		}
+		if err != nil {
+			invalid++
+			fmt.Fprintf(w, "%s: %v\n", f, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s: valid\n", f)
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d config file(s) invalid", invalid, len(args.ConfigFiles))
+	}
+	return nil
+}
+
+// WriteConfigSchema writes the JSON Schema for config files to w.
+func WriteConfigSchema(w io.Writer) error {
+	_, err := w.Write(config.Schema)
+	return err
+}
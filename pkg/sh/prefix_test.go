@@ -0,0 +1,115 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sh
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		writes     []string
+		wantBefore string
+		wantAfter  string
+	}{
+		{
+			name:       "partial write stays buffered",
+			writes:     []string{"Plan: 1 to add"},
+			wantBefore: "",
+			wantAfter:  "[app] Plan: 1 to add\n",
+		},
+		{
+			name:       "partial writes completed by newline",
+			writes:     []string{"Plan: ", "1 to add", "\n"},
+			wantBefore: "[app] Plan: 1 to add\n",
+			wantAfter:  "[app] Plan: 1 to add\n",
+		},
+		{
+			name:       "several lines in one write",
+			writes:     []string{"one\ntwo\nthree\n"},
+			wantBefore: "[app] one\n[app] two\n[app] three\n",
+			wantAfter:  "[app] one\n[app] two\n[app] three\n",
+		},
+		{
+			name:       "lines split across writes",
+			writes:     []string{"one\ntw", "o\nthr", "ee"},
+			wantBefore: "[app] one\n[app] two\n",
+			wantAfter:  "[app] one\n[app] two\n[app] three\n",
+		},
+		{
+			name:       "empty lines",
+			writes:     []string{"\n\n"},
+			wantBefore: "[app] \n[app] \n",
+			wantAfter:  "[app] \n[app] \n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewPrefixWriter(&buf, "[app] ", &sync.Mutex{})
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				require.NoError(t, err)
+				assert.Equal(t, len(s), n)
+			}
+			assert.Equal(t, tt.wantBefore, buf.String())
+
+			require.NoError(t, w.Flush())
+			assert.Equal(t, tt.wantAfter, buf.String())
+			require.NoError(t, w.Flush())
+			assert.Equal(t, tt.wantAfter, buf.String(), "a partial line must only be flushed once")
+		})
+	}
+}
+
+func TestPrefixWriter_Concurrent(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+	)
+	const lines = 200
+	for _, name := range []string{"a", "b", "c"} {
+		w := NewPrefixWriter(&buf, "["+name+"] ", &mu)
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				// lines are written in pieces to provoke interleaving
+				line := fmt.Sprintf("%s line %d\n", name, i)
+				for _, part := range []string{line[:2], line[2:]} {
+					_, err := w.Write([]byte(part))
+					assert.NoError(t, err)
+				}
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, got, 3*lines)
+	for _, line := range got {
+		name := line[1:2]
+		assert.True(t, strings.HasPrefix(line, "["+name+"] "+name+" line "), "interleaved line: %q", line)
+	}
+}
@@ -15,34 +15,130 @@
 package sh
 
 import (
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
 )
 
-type Shell struct{}
+// Shell executes commands. Stdin, Stdout, and Stderr default to those of the current process.
+// Logger defaults to the standard logger.
+type Shell struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Logger *log.Logger
+}
 
-func (s Shell) Execute(env map[string]string, workingDir string, cmd string, args ...string) error {
-	log.Println()
-	log.Println("Terraform command-line:")
-	log.Println("-----------------------")
-	log.Println(cmd, strings.Join(args, " "))
-	log.Println()
-	log.Println("Terraform environment:")
-	log.Println("----------------------")
+// Sensitive specifies what must be masked in debug output.
+type Sensitive struct {
+	// Values are masked wherever they occur.
+	Values []string
+	// Envs are names of environment variables whose values are masked completely.
+	Envs []string
+}
+
+// Execute runs the command. Sensitive information is masked in the debug output.
+func (s Shell) Execute(env map[string]string, sensitive Sensitive, workingDir string, cmd string, args ...string) error {
+	mask := NewMasker(sensitive.Values)
+	sensitiveEnvs := make(map[string]bool, len(sensitive.Envs))
+	for _, e := range sensitive.Envs {
+		sensitiveEnvs[e] = true
+	}
+
+	logger := s.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	logger.Println()
+	logger.Println("Terraform command-line:")
+	logger.Println("-----------------------")
+	logger.Println(mask(cmd + " " + strings.Join(args, " ")))
+	logger.Println()
+	logger.Println("Terraform environment:")
+	logger.Println("----------------------")
 
 	c := exec.Command(cmd, args...)
 	c.Dir = workingDir
 	c.Env = os.Environ()
 	for k, v := range env {
 		c.Env = append(c.Env, k+"="+v)
-		log.Printf("%s=%s\n", k, v)
+		if sensitiveEnvs[k] {
+			logger.Printf("%s=%s\n", k, MaskedValue)
+		} else {
+			logger.Printf("%s=%s\n", k, mask(v))
+		}
+	}
+
+	c.Stdout = s.Stdout
+	if c.Stdout == nil {
+		c.Stdout = os.Stdout
 	}
+	c.Stderr = s.Stderr
+	if c.Stderr == nil {
+		c.Stderr = os.Stderr
+	}
+	c.Stdin = s.Stdin
+	if c.Stdin == nil {
+		c.Stdin = os.Stdin
+	}
+
+	// Terraform is stopped gracefully on signals, so gotf must keep running until it has exited
+	// and temporary files, e.g. var files containing secrets, can be removed.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
 
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
-	c.Stdin = os.Stdin
+	if err := c.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				// An interrupt from the terminal is sent to Terraform as well. Forwarding it would
+				// make Terraform exit immediately as if it had been interrupted twice.
+				if sig == os.Interrupt {
+					continue
+				}
+				if err := c.Process.Signal(sig); err != nil {
+					logger.Println("Could not forward signal to Terraform:", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return c.Wait()
+}
 
-	return c.Run()
+// MaskedValue replaces sensitive values in output.
+const MaskedValue = "***"
+
+// NewMasker returns a function replacing all occurrences of the specified values with '***'.
+func NewMasker(values []string) func(string) string {
+	sorted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			sorted = append(sorted, v)
+		}
+	}
+	// replace longer values first in case values contain each other
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+
+	oldNew := make([]string, 0, 2*len(sorted))
+	for _, v := range sorted {
+		oldNew = append(oldNew, v, MaskedValue)
+	}
+	replacer := strings.NewReplacer(oldNew...)
+	return replacer.Replace
 }
@@ -0,0 +1,115 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sh
+
+import (
+	"bytes"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMasker(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		in     string
+		want   string
+	}{
+		{
+			name: "no values",
+			in:   "password=s3cr3t",
+			want: "password=s3cr3t",
+		},
+		{
+			name:   "all occurrences",
+			values: []string{"s3cr3t"},
+			in:     "a=s3cr3t b=s3cr3t",
+			want:   "a=*** b=***",
+		},
+		{
+			name:   "longest match first for shared prefix",
+			values: []string{"abc", "abcdef"},
+			in:     "x=abcdef y=abc",
+			want:   "x=*** y=***",
+		},
+		{
+			name:   "longest match first for contained value",
+			values: []string{"cd", "abcdef"},
+			in:     "x=abcdef y=cd",
+			want:   "x=*** y=***",
+		},
+		{
+			name:   "overlapping values",
+			values: []string{"abcd", "cdef"},
+			in:     "abcdef",
+			want:   "***ef",
+		},
+		{
+			name:   "empty values are ignored",
+			values: []string{"", "s3cr3t", ""},
+			in:     "a=s3cr3t b=",
+			want:   "a=*** b=",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NewMasker(tt.values)(tt.in))
+		})
+	}
+}
+
+// recordingWriter records each Write call separately.
+type recordingWriter struct {
+	writes []string
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.writes = append(w.writes, string(b))
+	return len(b), nil
+}
+
+func TestShell_Execute_MasksDebugOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires 'true' command")
+	}
+	recorder := &recordingWriter{}
+	// debug output of concurrently running modules is written in pieces via a PrefixWriter
+	output := NewPrefixWriter(recorder, "[app] ", &sync.Mutex{})
+	shell := Shell{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}, Logger: log.New(output, "", 0)}
+
+	// the secret is split across two args, which are logged as one line
+	err := shell.Execute(
+		map[string]string{"TF_VAR_password": "pass word", "TOKEN": "t0ken", "PLAIN": "plain"},
+		Sensitive{Values: []string{"pass word"}, Envs: []string{"TOKEN"}},
+		".", "true", "-var=password=pass", "word",
+	)
+	require.NoError(t, err)
+	require.NoError(t, output.Flush())
+
+	assert.Greater(t, len(recorder.writes), 1)
+	all := strings.Join(recorder.writes, "")
+	assert.NotContains(t, all, "pass word")
+	assert.NotContains(t, all, "t0ken")
+	assert.Contains(t, all, "[app] true -var=password=***\n")
+	assert.Contains(t, all, "[app] TF_VAR_password=***\n")
+	assert.Contains(t, all, "[app] TOKEN=***\n")
+	assert.Contains(t, all, "[app] PLAIN=plain\n")
+}
@@ -0,0 +1,214 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+
+	"github.com/craftypath/gotf/pkg/config"
+)
+
+const versionFileName = ".terraform-version"
+
+// FindVersionFile looks for a '.terraform-version' file in moduleDir and its parent
+// directories up to stopDir or the root of the repository, whichever comes first. It returns
+// the version specified in the file and the path of the file. If no file is found, empty
+// strings are returned.
+func FindVersionFile(moduleDir string, stopDir string) (string, string, error) {
+	dir, err := filepath.Abs(moduleDir)
+	if err != nil {
+		return "", "", err
+	}
+	stopDir, err = filepath.Abs(stopDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		path := filepath.Join(dir, versionFileName)
+		version, err := readVersionFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return "", "", err
+			}
+		} else if version != "" {
+			return version, path, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if dir == stopDir || config.IsVCSRoot(dir) || parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
+
+func readVersionFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// RequiredVersion returns the 'required_version' constraints from all 'terraform' blocks
+// in the module's configuration files joined with commas. An empty string is returned if none is set.
+func RequiredVersion(moduleDir string) (string, error) {
+	files, err := parseModule(moduleDir)
+	if err != nil {
+		return "", err
+	}
+
+	var constraints []string
+	for _, f := range files {
+		for _, b := range f.content.Blocks.OfType("terraform") {
+			content, _, diags := b.Body.PartialContent(terraformBlockSchema)
+			if diags.HasErrors() {
+				return "", diags
+			}
+			attr, ok := content.Attributes["required_version"]
+			if !ok {
+				continue
+			}
+			var constraint string
+			if diags := gohcl.DecodeExpression(attr.Expr, nil, &constraint); diags.HasErrors() {
+				return "", fmt.Errorf("invalid required_version: %w", diags)
+			}
+			constraints = append(constraints, constraint)
+		}
+	}
+	return strings.Join(constraints, ", "), nil
+}
+
+// Variable is a variable declared in a module via a 'variable' block.
+type Variable struct {
+	Name string
+	// File is the file the variable is declared in.
+	File string
+	// Required reports whether the variable has no default value, so a value must be provided.
+	Required bool
+}
+
+// Variables returns the variables declared in the module's '.tf' and '.tf.json' files by name.
+// If the module directory doesn't contain any configuration files, nil is returned.
+func Variables(moduleDir string) (map[string]Variable, error) {
+	files, err := parseModule(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	variables := make(map[string]Variable)
+	for _, f := range files {
+		for _, b := range f.content.Blocks.OfType("variable") {
+			content, _, diags := b.Body.PartialContent(variableBlockSchema)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			_, hasDefault := content.Attributes["default"]
+			name := b.Labels[0]
+			variables[name] = Variable{Name: name, File: f.name, Required: !hasDefault}
+		}
+	}
+	return variables, nil
+}
+
+var (
+	// moduleSchema only covers the blocks gotf is interested in, all others are ignored.
+	moduleSchema = &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "terraform"},
+			{Type: "variable", LabelNames: []string{"name"}},
+		},
+	}
+	terraformBlockSchema = &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "required_version"}},
+	}
+	variableBlockSchema = &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "default"}},
+	}
+)
+
+// moduleFile is the content of a configuration file of a module matching moduleSchema.
+type moduleFile struct {
+	name    string
+	content *hcl.BodyContent
+}
+
+// parseModule parses all '.tf' and '.tf.json' files in the module directory.
+func parseModule(moduleDir string) ([]moduleFile, error) {
+	tfFiles, err := filepath.Glob(filepath.Join(moduleDir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	jsonFiles, err := filepath.Glob(filepath.Join(moduleDir, "*.tf.json"))
+	if err != nil {
+		return nil, err
+	}
+	names := append(tfFiles, jsonFiles...)
+	sort.Strings(names)
+
+	parser := hclparse.NewParser()
+	files := make([]moduleFile, 0, len(names))
+	for _, name := range names {
+		file, err := parseFile(parser, name)
+		if err != nil {
+			return nil, err
+		}
+		content, _, diags := file.Body.PartialContent(moduleSchema)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		files = append(files, moduleFile{name: name, content: content})
+	}
+	return files, nil
+}
+
+// parseFile parses a file in HCL native syntax or, if its name ends with '.json', in JSON syntax.
+func parseFile(parser *hclparse.Parser, name string) (*hcl.File, error) {
+	var (
+		file  *hcl.File
+		diags hcl.Diagnostics
+	)
+	if strings.HasSuffix(name, ".json") {
+		file, diags = parser.ParseJSONFile(name)
+	} else {
+		file, diags = parser.ParseHCLFile(name)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return file, nil
+}
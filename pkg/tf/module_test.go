@@ -0,0 +1,135 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindVersionFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		moduleDir string
+		stopDir   string
+		want      string
+		wantFile  string
+	}{
+		{
+			name:      "version file in module dir",
+			moduleDir: "testdata/modules/pinned",
+			stopDir:   "testdata",
+			want:      "1.6.6",
+			wantFile:  "testdata/modules/pinned/.terraform-version",
+		},
+		{
+			name:      "version file in parent dir",
+			moduleDir: "testdata/modules/app",
+			stopDir:   "testdata",
+			want:      "1.5.7",
+			wantFile:  "testdata/modules/.terraform-version",
+		},
+		{
+			name:      "parent dir beyond stop dir",
+			moduleDir: "testdata/modules/app",
+			stopDir:   "testdata/modules/app",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotFile, err := FindVersionFile(tt.moduleDir, tt.stopDir)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			if tt.wantFile != "" {
+				wantFile, _ := filepath.Abs(tt.wantFile)
+				assert.Equal(t, wantFile, gotFile)
+			} else {
+				assert.Empty(t, gotFile)
+			}
+		})
+	}
+}
+
+func TestFindVersionFile_VCSRoot(t *testing.T) {
+	dir := t.TempDir()
+	repoDir := filepath.Join(dir, "repo")
+	moduleDir := filepath.Join(repoDir, "modules", "app")
+	require.NoError(t, os.MkdirAll(moduleDir, 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(repoDir, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".terraform-version"), []byte("1.5.7\n"), 0644))
+
+	// the config file is outside of the repository, so the stop dir is never reached
+	got, gotFile, err := FindVersionFile(moduleDir, t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	assert.Empty(t, gotFile)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".terraform-version"), []byte("latest:^1\\.6\n"), 0644))
+	got, gotFile, err = FindVersionFile(moduleDir, t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, `latest:^1\.6`, got)
+	assert.Equal(t, filepath.Join(repoDir, ".terraform-version"), gotFile)
+}
+
+func TestRequiredVersion(t *testing.T) {
+	got, err := RequiredVersion("testdata/modules/app")
+	require.NoError(t, err)
+	assert.Equal(t, "< 1.7, >= 1.4", got)
+
+	got, err = RequiredVersion("testdata/modules/pinned")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestVariables(t *testing.T) {
+	tests := []struct {
+		moduleDir string
+		want      map[string]Variable
+	}{
+		{
+			moduleDir: "testdata/modules/app",
+			want: map[string]Variable{
+				"name":   {Name: "name", File: "testdata/modules/app/main.tf", Required: true},
+				"tags":   {Name: "tags", File: "testdata/modules/app/main.tf"},
+				"script": {Name: "script", File: "testdata/modules/app/main.tf"},
+				"zones":  {Name: "zones", File: "testdata/modules/app/main.tf"},
+				"owner":  {Name: "owner", File: "testdata/modules/app/main.tf"},
+			},
+		},
+		{
+			moduleDir: "testdata/modules/json",
+			want: map[string]Variable{
+				"name":     {Name: "name", File: "testdata/modules/json/main.tf", Required: true},
+				"region":   {Name: "region", File: "testdata/modules/json/main.tf.json"},
+				"replicas": {Name: "replicas", File: "testdata/modules/json/main.tf.json", Required: true},
+				"zones":    {Name: "zones", File: "testdata/modules/json/list.tf.json", Required: true},
+			},
+		},
+		{
+			moduleDir: "testdata/modules/pinned",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.moduleDir, func(t *testing.T) {
+			got, err := Variables(tt.moduleDir)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"archive/zip"
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// planEntry is the entry every plan file contains besides the state and config snapshots.
+const planEntry = "tfplan"
+
+// planFileArg returns the plan file if the args apply a saved plan, e.g. 'apply tf.plan'.
+// Terraform rejects vars and var files in this case because they are stored in the plan.
+func (tf *Terraform) planFileArg(args []string) string {
+	command, commandArgs := splitCommand(args)
+	if command != "apply" || len(commandArgs) == 0 {
+		return ""
+	}
+
+	// Terraform stops parsing flags at the first positional arg, so the plan file must be last
+	planFile := commandArgs[len(commandArgs)-1]
+	if strings.HasPrefix(planFile, "-") {
+		return ""
+	}
+	path := planFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(tf.moduleDir, path)
+	}
+	if err := checkPlanFile(path); err != nil {
+		tf.logger.Printf("Argument %s is not a plan file: %v\n", planFile, err)
+		return ""
+	}
+	return planFile
+}
+
+// checkPlanFile checks that the file is a plan archive as written by 'terraform plan -out'.
+func checkPlanFile(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name == planEntry {
+			return nil
+		}
+	}
+	return errors.New("archive does not contain a plan")
+}
@@ -0,0 +1,85 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/craftypath/gotf/pkg/config"
+)
+
+func writeZip(t *testing.T, path string, entries ...string) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, e := range entries {
+		_, err := w.Create(e)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+}
+
+func TestTerraform_Execute_PlanFile(t *testing.T) {
+	moduleDir := t.TempDir()
+	writeZip(t, filepath.Join(moduleDir, "tf.plan"), "tfplan", "tfstate", "tfconfig/m-/main.tf")
+	writeZip(t, filepath.Join(moduleDir, "other.zip"), "README.md")
+	require.NoError(t, os.WriteFile(filepath.Join(moduleDir, "prod.tfvars"), []byte(`region = "westeurope"`), 0644))
+	absPlan := filepath.Join(t.TempDir(), "abs.plan")
+	writeZip(t, absPlan, "tfplan")
+
+	cfg := &config.Config{
+		VarFiles: []string{"global.tfvars"},
+		Vars:     map[string]string{"region": "westeurope"},
+		Envs:     map[string]string{"BAR": "bar"},
+	}
+	tests := []struct {
+		name     string
+		args     []string
+		wantVars bool
+	}{
+		{name: "plan file", args: []string{"apply", "tf.plan"}},
+		{name: "plan file after flags", args: []string{"apply", "-auto-approve", "-parallelism=5", "tf.plan"}},
+		{name: "absolute plan file", args: []string{"apply", absPlan}},
+		{name: "no plan file", args: []string{"apply", "-auto-approve"}, wantVars: true},
+		{name: "flag value", args: []string{"apply", "-var-file", "prod.tfvars"}, wantVars: true},
+		{name: "zip without plan", args: []string{"apply", "other.zip"}, wantVars: true},
+		{name: "missing file", args: []string{"apply", "missing.plan"}, wantVars: true},
+		{name: "chdir", args: []string{"-chdir=other", "apply", "tf.plan"}, wantVars: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := &fakeShell{}
+			tf := NewTerraform(cfg, moduleDir, nil, true, false, shell, "terraform")
+
+			require.NoError(t, tf.Execute(tt.args...))
+
+			if tt.wantVars {
+				assert.Equal(t, "westeurope", shell.env["TF_VAR_region"])
+				assert.Equal(t, `-var-file="global.tfvars"`, shell.env["TF_CLI_ARGS_apply"])
+				assert.Equal(t, "bar", shell.env["BAR"])
+			} else {
+				assert.Equal(t, map[string]string{"BAR": "bar"}, shell.env, "only envs must be set")
+			}
+			assert.Equal(t, tt.args, shell.args)
+		})
+	}
+}
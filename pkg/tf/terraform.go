@@ -18,16 +18,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/craftypath/gotf/pkg/config"
+	"github.com/craftypath/gotf/pkg/sh"
 )
 
 type (
 	Shell interface {
-		Execute(env map[string]string, workingDir string, cmd string, args ...string) error
+		Execute(env map[string]string, sensitive sh.Sensitive, workingDir string, cmd string, args ...string) error
 	}
 
 	Terraform struct {
@@ -38,6 +41,7 @@ type (
 		skipBackendCheck bool
 		shell            Shell
 		binaryPath       string
+		logger           *log.Logger
 	}
 )
 
@@ -52,16 +56,48 @@ func NewTerraform(config *config.Config, moduleDir string, params map[string]str
 		skipBackendCheck: skipBackendCheck,
 		noVars:           noVars,
 		binaryPath:       binaryPath,
+		logger:           log.Default(),
 	}
 }
 
+// SetLogger sets the logger for debug output, which defaults to the standard logger.
+func (tf *Terraform) SetLogger(logger *log.Logger) {
+	tf.logger = logger
+}
+
 func (tf *Terraform) Execute(args ...string) error {
 	env := map[string]string{}
 	stringMapAppend(env, tf.config.Envs)
-	if !tf.noVars {
-		tf.appendVarFileArgs(env)
-		tf.appendVarArgs(env)
+	noVars := tf.noVars
+	if !noVars {
+		if planFile := tf.planFileArg(args); planFile != "" {
+			tf.logger.Println("Not adding vars because plan file is applied:", planFile)
+			noVars = true
+		}
+	}
+	if !noVars {
+		vars := tf.declaredVars()
+		varFiles := tf.config.VarFiles
+		if tf.config.VarsMode != config.VarsModeFile {
+			tf.appendVarArgs(env, vars)
+		} else if takesVars(terraformCommand(args)) {
+			// the var file may contain secrets, so it is only written for commands using it
+			varsFile, err := tf.writeVarsFile(vars)
+			if err != nil {
+				return err
+			}
+			if varsFile != "" {
+				defer tf.removeVarsFile(varsFile)
+				// vars rank below var files from the config, but unlike 'TF_VAR_' environment
+				// variables above 'terraform.tfvars' and '*.auto.tfvars' files
+				varFiles = append([]string{varsFile}, varFiles...)
+			}
+		}
+		tf.appendVarFileArgs(env, varFiles)
 		tf.appendBackendConfigs(env)
+		if err := tf.checkRequiredVars(env, vars, tf.config.VarFiles, args); err != nil {
+			return err
+		}
 	}
 
 	if !tf.skipBackendCheck {
@@ -69,17 +105,85 @@ func (tf *Terraform) Execute(args ...string) error {
 			return err
 		}
 	}
-	return tf.shell.Execute(env, tf.moduleDir, tf.binaryPath, args...)
+	return tf.shell.Execute(env, tf.sensitive(env), tf.moduleDir, tf.binaryPath, args...)
 }
 
-func (tf *Terraform) appendVarArgs(env map[string]string) {
-	for k, v := range tf.config.Vars {
+func (tf *Terraform) appendVarArgs(env map[string]string, vars map[string]string) {
+	for k, v := range vars {
 		env["TF_VAR_"+k] = v
 	}
 }
 
-func (tf *Terraform) appendVarFileArgs(env map[string]string) {
-	varFiles := tf.config.VarFiles
+// declaredVars returns the vars declared by the module. Other vars would only cause warnings
+// about undeclared variables, so they are dropped. If the module's variables cannot be
+// determined, all vars are returned.
+func (tf *Terraform) declaredVars() map[string]string {
+	variables, err := Variables(tf.moduleDir)
+	if err != nil {
+		tf.logger.Println("Passing all vars because declared variables could not be determined:", err)
+		return tf.config.Vars
+	}
+	if variables == nil {
+		return tf.config.Vars
+	}
+
+	vars := make(map[string]string, len(tf.config.Vars))
+	var dropped []string
+	for k, v := range tf.config.Vars {
+		if _, ok := variables[k]; ok {
+			vars[k] = v
+		} else {
+			dropped = append(dropped, k)
+		}
+	}
+	if len(dropped) > 0 {
+		sort.Strings(dropped)
+		tf.logger.Printf("Dropping vars not declared in module %s: %s\n", tf.moduleDir, strings.Join(dropped, ", "))
+	}
+	return vars
+}
+
+// writeVarsFile writes the vars to a temporary var file and returns its path. If there are no
+// vars, no file is written and an empty string is returned.
+func (tf *Terraform) writeVarsFile(vars map[string]string) (string, error) {
+	if len(vars) == 0 {
+		return "", nil
+	}
+
+	values := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		if tf.config.JSONVars[k] {
+			values[k] = json.RawMessage(v)
+		} else {
+			values[k] = v
+		}
+	}
+
+	// temp files are only readable by the current user, which matters for sensitive vars
+	file, err := ioutil.TempFile("", "gotf-*.tfvars.json")
+	if err != nil {
+		return "", fmt.Errorf("could not create var file: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(values); err != nil {
+		tf.removeVarsFile(file.Name())
+		return "", fmt.Errorf("could not write var file: %w", err)
+	}
+	tf.logger.Println("Wrote vars to", file.Name())
+	return file.Name(), nil
+}
+
+func (tf *Terraform) removeVarsFile(path string) {
+	if err := os.Remove(path); err != nil {
+		tf.logger.Println("Could not remove var file:", err)
+	}
+}
+
+func (tf *Terraform) appendVarFileArgs(env map[string]string, varFiles []string) {
 	if len(varFiles) > 0 {
 		sb := strings.Builder{}
 		for _, f := range varFiles {
@@ -152,6 +256,32 @@ func (tf *Terraform) checkBackendConfig(args ...string) error {
 	return nil
 }
 
+// sensitive returns what must be masked in debug output. Envs and vars are masked if listed
+// as sensitive in the config or if their names look sensitive. Backend configs with sensitive
+// names are masked as part of the init args.
+func (tf *Terraform) sensitive(env map[string]string) sh.Sensitive {
+	sensitive := sh.Sensitive{
+		Values: append([]string{}, tf.config.SensitiveValues...),
+	}
+	for k := range tf.config.Envs {
+		if _, ok := env[k]; ok && tf.config.IsSensitiveEnv(k) {
+			sensitive.Envs = append(sensitive.Envs, k)
+		}
+	}
+	for k := range tf.config.Vars {
+		if _, ok := env["TF_VAR_"+k]; ok && tf.config.IsSensitiveVar(k) {
+			sensitive.Envs = append(sensitive.Envs, "TF_VAR_"+k)
+		}
+	}
+	for k, v := range tf.config.BackendConfigs {
+		if tf.config.IsSensitiveBackendConfig(k) {
+			sensitive.Values = append(sensitive.Values, fmt.Sprintf("'%v'", v))
+		}
+	}
+	sort.Strings(sensitive.Envs)
+	return sensitive
+}
+
 func stringMapAppend(target map[string]string, src map[string]string) {
 	for k, v := range src {
 		target[k] = v
@@ -0,0 +1,214 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/craftypath/gotf/pkg/config"
+	"github.com/craftypath/gotf/pkg/sh"
+)
+
+type fakeShell struct {
+	env       map[string]string
+	sensitive sh.Sensitive
+	args      []string
+	// onExecute is called with the environment while executing, e.g. to inspect temporary files
+	onExecute func(env map[string]string)
+}
+
+func (s *fakeShell) Execute(env map[string]string, sensitive sh.Sensitive, _ string, _ string, args ...string) error {
+	s.env = env
+	s.sensitive = sensitive
+	s.args = args
+	if s.onExecute != nil {
+		s.onExecute(env)
+	}
+	return nil
+}
+
+func TestTerraform_Execute_Sensitive(t *testing.T) {
+	cfg := &config.Config{
+		Vars: map[string]string{
+			"db_password": "pw",
+			"api_token":   "token",
+			"custom":      "custom-value",
+			"region":      "westeurope",
+		},
+		Envs: map[string]string{
+			"ARM_ACCESS_KEY": "access-key",
+			"MY_CREDENTIALS": "credentials",
+			"BAR":            "bar",
+		},
+		BackendConfigs: map[string]interface{}{
+			"storage_account_name": "account",
+			"sas_token":            "sas",
+			"key":                  "dev.terraform.tfstate",
+		},
+		SensitiveValues: []string{"from-secret-source"},
+		SensitiveVars:   []string{"custom"},
+		SensitiveEnvs:   []string{"MY_CREDENTIALS"},
+	}
+	shell := &fakeShell{}
+	tf := NewTerraform(cfg, t.TempDir(), nil, true, false, shell, "terraform")
+
+	require.NoError(t, tf.Execute("plan"))
+
+	assert.Equal(t, []string{"from-secret-source", "'sas'"}, shell.sensitive.Values)
+	assert.Equal(t, []string{
+		"ARM_ACCESS_KEY",
+		"MY_CREDENTIALS",
+		"TF_VAR_api_token",
+		"TF_VAR_custom",
+		"TF_VAR_db_password",
+	}, shell.sensitive.Envs)
+}
+
+func TestTerraform_Execute_VarsModeFile(t *testing.T) {
+	cfg := &config.Config{
+		VarsMode: config.VarsModeFile,
+		VarFiles: []string{"global.tfvars"},
+		Vars: map[string]string{
+			"region":   "westeurope",
+			"count":    "3",
+			"enabled":  "true",
+			"tags":     `{"env":"dev","owners":["a","b"]}`,
+			"port":     "8080",
+			"html":     "<b>&</b>",
+			"multiple": "line1\nline2",
+		},
+		JSONVars: map[string]bool{"count": true, "enabled": true, "tags": true},
+		Envs:     map[string]string{"BAR": "bar"},
+	}
+
+	var varsFile, content string
+	shell := &fakeShell{onExecute: func(env map[string]string) {
+		m := regexp.MustCompile(`^-var-file="([^"]+)" -var-file="global.tfvars"$`).FindStringSubmatch(env["TF_CLI_ARGS_plan"])
+		require.NotNil(t, m, "generated var file must be passed first")
+		varsFile = m[1]
+		b, err := os.ReadFile(varsFile)
+		require.NoError(t, err)
+		content = string(b)
+	}}
+	tf := NewTerraform(cfg, t.TempDir(), nil, true, false, shell, "terraform")
+
+	require.NoError(t, tf.Execute("plan"))
+
+	assert.JSONEq(t, `{
+		"region": "westeurope",
+		"count": 3,
+		"enabled": true,
+		"tags": {"env": "dev", "owners": ["a", "b"]},
+		"port": "8080",
+		"html": "<b>&</b>",
+		"multiple": "line1\nline2"
+	}`, content)
+	assert.Contains(t, content, `"html": "<b>&</b>"`)
+	for k := range shell.env {
+		assert.NotContains(t, k, "TF_VAR_")
+	}
+	assert.Equal(t, "bar", shell.env["BAR"])
+	_, err := os.Stat(varsFile)
+	assert.True(t, os.IsNotExist(err), "generated var file must be removed")
+}
+
+func TestTerraform_Execute_VarsModeFile_CommandWithoutVars(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	cfg := &config.Config{
+		VarsMode: config.VarsModeFile,
+		Vars:     map[string]string{"db_password": "s3cr3t"},
+	}
+	for _, args := range [][]string{{"init"}, {"output", "-json"}, {"state", "list"}, {"-chdir=other", "version"}} {
+		shell := &fakeShell{onExecute: func(env map[string]string) {
+			entries, err := os.ReadDir(tmpDir)
+			require.NoError(t, err)
+			assert.Empty(t, entries, "no var file must be written for %v", args)
+		}}
+		tf := NewTerraform(cfg, t.TempDir(), nil, true, false, shell, "terraform")
+
+		require.NoError(t, tf.Execute(args...))
+		assert.Empty(t, shell.env)
+	}
+}
+
+func TestTerraform_Execute_VarsModeFile_NoVars(t *testing.T) {
+	cfg := &config.Config{
+		VarsMode: config.VarsModeFile,
+		Vars:     map[string]string{"region": "westeurope"},
+	}
+	shell := &fakeShell{}
+	tf := NewTerraform(cfg, t.TempDir(), nil, true, true, shell, "terraform")
+
+	require.NoError(t, tf.Execute("apply", "plan.tfplan"))
+
+	assert.Empty(t, shell.env)
+}
+
+func TestTerraform_Execute_DeclaredVars(t *testing.T) {
+	cfg := &config.Config{
+		Vars: map[string]string{
+			"name":        "app",
+			"tags":        `{"owner":"me"}`,
+			"region":      "westeurope",
+			"from_env":    "env-file-value",
+			"db_password": "pw",
+		},
+		JSONVars: map[string]bool{"tags": true},
+	}
+
+	tests := []struct {
+		name     string
+		varsMode string
+		want     map[string]string
+	}{
+		{
+			name:     "env",
+			varsMode: config.VarsModeEnv,
+			want:     map[string]string{"TF_VAR_name": "app", "TF_VAR_tags": `{"owner":"me"}`},
+		},
+		{
+			name:     "file",
+			varsMode: config.VarsModeFile,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.VarsMode = tt.varsMode
+			var content string
+			shell := &fakeShell{onExecute: func(env map[string]string) {
+				if m := regexp.MustCompile(`^-var-file="([^"]+)"$`).FindStringSubmatch(env["TF_CLI_ARGS_plan"]); m != nil {
+					b, err := os.ReadFile(m[1])
+					require.NoError(t, err)
+					content = string(b)
+				}
+			}}
+			tf := NewTerraform(cfg, "testdata/modules/app", nil, true, false, shell, "terraform")
+
+			require.NoError(t, tf.Execute("plan"))
+
+			if tt.want != nil {
+				assert.Equal(t, tt.want, shell.env)
+			} else {
+				assert.JSONEq(t, `{"name": "app", "tags": {"owner": "me"}}`, content)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+/*
+terraform {
+  required_version = "0.12.0"
+}
+*/
+
+terraform {
+  required_version = "< 1.7"
+}
+
+variable "name" {}
+
+variable "tags" {
+  type    = map(string)
+  default = {
+    "owner" = "${var.name}-{team}"
+  }
+}
+
+variable "script" {
+  default = <<-EOT
+    terraform {
+      required_version = "0.11.0"
+    }
+  EOT
+}
+
+locals {
+  greeting = "Hello, ${var.name == "" ? "world" : "${var.name}"}!"
+}
+
+variable "zones" {
+  default = [
+    "a",
+    "b",
+  ]
+
+  validation {
+    condition = (
+      length(var.zones) > 0
+    )
+    error_message = "At least one zone is required."
+  }
+}
+
+variable "owner" {
+  type = object({
+    name = string
+    team = optional(string,
+    "platform")
+  })
+  default = {
+    name = "me"
+  }
+}
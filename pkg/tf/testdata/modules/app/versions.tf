@@ -0,0 +1,11 @@
+terraform {
+  # comments with { braces are ignored
+  required_version = ">= 1.4"
+
+  required_providers {
+    null = {
+      source  = "hashicorp/null"
+      version = "~> 3.0"
+    }
+  }
+}
@@ -0,0 +1,13 @@
+variable "location" {}
+
+variable "name" {
+  type = string
+}
+
+variable "tags" {
+  type = map(string)
+}
+
+variable "replicas" {
+  default = 1
+}
@@ -0,0 +1,326 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/craftypath/gotf/pkg/config"
+)
+
+// autoVarFilePatterns are the var files Terraform loads automatically from the module directory.
+var autoVarFilePatterns = []string{"terraform.tfvars", "terraform.tfvars.json", "*.auto.tfvars", "*.auto.tfvars.json"}
+
+// MissingVariablesError lists required variables for which no value is provided.
+type MissingVariablesError struct {
+	ModuleDir string
+	// Params describes the params in use, e.g. 'environment=dev'.
+	Params    string
+	Variables []Variable
+}
+
+func (e *MissingVariablesError) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("module %s is missing values for %d required variable(s)", e.ModuleDir, len(e.Variables)))
+	if e.Params != "" {
+		sb.WriteString(fmt.Sprintf(" (%s)", e.Params))
+	}
+	sb.WriteString(":")
+	for _, v := range e.Variables {
+		sb.WriteString(fmt.Sprintf("\n  %s (declared in %s)", v.Name, v.File))
+	}
+	return sb.String()
+}
+
+// checkRequiredVars returns a *MissingVariablesError if the module declares variables without
+// default values which are neither provided by the config, var files, 'TF_VAR_' environment
+// variables, nor Terraform args. Environment variables are those of the current process
+// overlaid with env, i.e. those Terraform is run with. If it cannot be determined reliably
+// which variables are provided, the check is skipped.
+func (tf *Terraform) checkRequiredVars(env map[string]string, vars map[string]string, varFiles []string, args []string) error {
+	command, commandArgs := splitCommand(args)
+	if !takesVars(command) {
+		return nil
+	}
+
+	variables, err := Variables(tf.moduleDir)
+	if err != nil || variables == nil {
+		return nil
+	}
+
+	provided := make(map[string]bool)
+	for k := range vars {
+		provided[k] = true
+	}
+	terraformEnv := make(map[string]string)
+	for _, e := range os.Environ() {
+		name, value, _ := strings.Cut(e, "=")
+		terraformEnv[name] = value
+	}
+	stringMapAppend(terraformEnv, env)
+	for name := range terraformEnv {
+		if strings.HasPrefix(name, "TF_VAR_") {
+			provided[strings.TrimPrefix(name, "TF_VAR_")] = true
+		}
+	}
+
+	files := make([]string, 0, len(varFiles))
+	for _, f := range varFiles {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(tf.moduleDir, f)
+		}
+		files = append(files, f)
+	}
+	autoVarFiles, err := AutoVarFiles(tf.moduleDir)
+	if err != nil {
+		return nil
+	}
+	files = append(files, autoVarFiles...)
+	// Terraform appends args from these environment variables to the command's args
+	envArgs, err := cliArgsFromEnv(terraformEnv, command)
+	if err != nil {
+		tf.logger.Printf("Skipping check for required variables: %v\n", err)
+		return nil
+	}
+	argVars, argVarFiles := varArgs(append(append([]string{}, commandArgs...), envArgs...))
+	for _, name := range argVars {
+		provided[name] = true
+	}
+	for _, f := range argVarFiles {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(tf.moduleDir, f)
+		}
+		files = append(files, f)
+	}
+
+	for _, f := range files {
+		keys, err := varFileKeys(f)
+		if err != nil {
+			tf.logger.Printf("Skipping check for required variables because var file %s could not be read: %v\n", f, err)
+			return nil
+		}
+		for _, k := range keys {
+			provided[k] = true
+		}
+	}
+
+	var missing []Variable
+	for name, v := range variables {
+		if v.Required && !provided[name] {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Name < missing[j].Name })
+	return &MissingVariablesError{ModuleDir: tf.moduleDir, Params: config.DescribeParams(tf.config.RunParams()), Variables: missing}
+}
+
+// splitCommand returns the Terraform command and its args. If global options such as '-chdir'
+// precede the command, an empty command is returned because the module directory may differ.
+func splitCommand(args []string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", nil
+	}
+	return args[0], args[1:]
+}
+
+// terraformCommand returns the Terraform command, skipping global options such as '-chdir'.
+func terraformCommand(args []string) string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
+
+func takesVars(command string) bool {
+	for _, c := range commandsWithVars {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+// varArgs returns the names of vars set via '-var' and the files specified via '-var-file'.
+func varArgs(args []string) ([]string, []string) {
+	var vars, varFiles []string
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimPrefix(args[i], "-")
+		if arg == args[i] {
+			continue
+		}
+		arg = strings.TrimPrefix(arg, "-")
+		name, value, hasValue := strings.Cut(arg, "=")
+		if name != "var" && name != "var-file" {
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				break
+			}
+			i++
+			value = args[i]
+		}
+		if name == "var" {
+			vars = append(vars, strings.SplitN(value, "=", 2)[0])
+		} else {
+			varFiles = append(varFiles, value)
+		}
+	}
+	return vars, varFiles
+}
+
+// cliArgsFromEnv returns the args set via the 'TF_CLI_ARGS' and 'TF_CLI_ARGS_<command>'
+// environment variables.
+func cliArgsFromEnv(env map[string]string, command string) ([]string, error) {
+	var args []string
+	for _, name := range []string{"TF_CLI_ARGS", "TF_CLI_ARGS_" + command} {
+		words, err := splitArgs(env[name])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", name, err)
+		}
+		args = append(args, words...)
+	}
+	return args, nil
+}
+
+// splitArgs splits a string into args like a POSIX shell, i.e. at unquoted whitespace,
+// removing single and double quotes and backslash escapes.
+func splitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			// within double quotes, backslashes only escape some characters
+			if quote == '"' && !strings.ContainsRune("\\\"$`", r) {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// varFileKeys returns the names of the variables set in a '.tfvars' or '.tfvars.json' file.
+func varFileKeys(path string) ([]string, error) {
+	defs, err := VarFileDefinitions(path, "")
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(defs))
+	for k := range defs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// VarFileDefinitions returns the definitions of the variables set in a '.tfvars' or
+// '.tfvars.json' file, recorded with the specified source. Strings are returned as is and
+// other values JSON-encoded. Expressions which cannot be evaluated without context, e.g.
+// function calls, are returned as not evaluated.
+func VarFileDefinitions(path string, source string) (map[string]config.Definition, error) {
+	file, err := parseFile(hclparse.NewParser(), path)
+	if err != nil {
+		return nil, err
+	}
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	defs := make(map[string]config.Definition, len(attrs))
+	for name, attr := range attrs {
+		def := config.Definition{Origin: config.Origin{Source: source, File: path, Key: name}}
+		if value, diags := attr.Expr.Value(nil); !diags.HasErrors() && value.IsWhollyKnown() {
+			if value.Type() == cty.String && !value.IsNull() {
+				def.Value = value.AsString()
+				def.Evaluated = true
+			} else if b, err := ctyjson.Marshal(value, value.Type()); err == nil {
+				def.Value = string(b)
+				def.Evaluated = true
+			}
+		}
+		defs[name] = def
+	}
+	return defs, nil
+}
+
+// AutoVarFiles returns the var files Terraform loads automatically from the module directory,
+// in the order they are loaded, i.e. later files take precedence.
+func AutoVarFiles(moduleDir string) ([]string, error) {
+	var files, autoFiles []string
+	for _, pattern := range autoVarFilePatterns {
+		matches, err := filepath.Glob(filepath.Join(moduleDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(pattern, "*") {
+			autoFiles = append(autoFiles, matches...)
+		} else {
+			files = append(files, matches...)
+		}
+	}
+	// '*.auto.tfvars' and '*.auto.tfvars.json' files are loaded in lexical order of their names
+	sort.Strings(autoFiles)
+	return append(files, autoFiles...), nil
+}
@@ -0,0 +1,204 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/craftypath/gotf/pkg/config"
+)
+
+func TestTerraform_Execute_RequiredVars(t *testing.T) {
+	tests := []struct {
+		name     string
+		vars     map[string]string
+		varFiles []string
+		envs     map[string]string
+		cfgEnvs  map[string]string
+		args     []string
+		wantErr  string
+	}{
+		{
+			name: "all missing",
+			args: []string{"plan"},
+			wantErr: `module testdata/modules/required is missing values for 3 required variable(s) (environment=dev):
+  location (declared in testdata/modules/required/main.tf)
+  name (declared in testdata/modules/required/main.tf)
+  tags (declared in testdata/modules/required/main.tf)`,
+		},
+		{
+			name: "some provided by vars",
+			vars: map[string]string{"location": "westeurope", "tags": "{}"},
+			args: []string{"apply"},
+			wantErr: `module testdata/modules/required is missing values for 1 required variable(s) (environment=dev):
+  name (declared in testdata/modules/required/main.tf)`,
+		},
+		{
+			name:     "provided by var files",
+			varFiles: []string{"location.tfvars", "name.tfvars.json"},
+			args:     []string{"plan"},
+		},
+		{
+			name:     "provided by args",
+			varFiles: []string{"location.tfvars"},
+			args:     []string{"plan", "-var", "name=app", "-no-color"},
+		},
+		{
+			name: "provided by var file arg and environment",
+			envs: map[string]string{"TF_VAR_tags": "{}"},
+			args: []string{"plan", "-var=location=westeurope", "--var-file=name.tfvars.json"},
+		},
+		{
+			name: "provided by Terraform args from environment",
+			envs: map[string]string{
+				"TF_CLI_ARGS":       `-var 'location=west europe'`,
+				"TF_CLI_ARGS_plan":  `-var-file="name.tfvars.json" -var=tags={}`,
+				"TF_CLI_ARGS_apply": "-var=unused=value",
+			},
+			args: []string{"plan"},
+		},
+		{
+			name:     "provided by config envs",
+			varFiles: []string{"location.tfvars"},
+			cfgEnvs:  map[string]string{"TF_VAR_name": "app"},
+			args:     []string{"plan"},
+		},
+		{
+			name:    "provided by Terraform args from config envs",
+			cfgEnvs: map[string]string{"TF_CLI_ARGS": "-var-file=location.tfvars", "TF_CLI_ARGS_plan": "-var name=app"},
+			args:    []string{"plan"},
+		},
+		{
+			name:    "config envs override environment",
+			envs:    map[string]string{"TF_CLI_ARGS_plan": "-var-file=location.tfvars -var=name=app"},
+			cfgEnvs: map[string]string{"TF_CLI_ARGS_plan": "-var=name=app"},
+			args:    []string{"plan"},
+			wantErr: `module testdata/modules/required is missing values for 2 required variable(s) (environment=dev):
+  location (declared in testdata/modules/required/main.tf)
+  tags (declared in testdata/modules/required/main.tf)`,
+		},
+		{
+			name: "Terraform args from environment for other command",
+			envs: map[string]string{"TF_CLI_ARGS_apply": "-var-file=location.tfvars -var=name=app"},
+			args: []string{"plan"},
+			wantErr: `module testdata/modules/required is missing values for 3 required variable(s) (environment=dev):
+  location (declared in testdata/modules/required/main.tf)
+  name (declared in testdata/modules/required/main.tf)
+  tags (declared in testdata/modules/required/main.tf)`,
+		},
+		{
+			name: "unparsable Terraform args from environment",
+			envs: map[string]string{"TF_CLI_ARGS": `-var "name=app`},
+			args: []string{"plan"},
+		},
+		{
+			name: "command without vars",
+			args: []string{"init"},
+		},
+		{
+			name: "global option",
+			args: []string{"-chdir=other", "plan"},
+		},
+		{
+			name:     "unparsable var file",
+			varFiles: []string{"broken.tfvars"},
+			args:     []string{"plan"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.envs {
+				t.Setenv(k, v)
+			}
+			cfg := &config.Config{
+				Params: map[string]interface{}{"environment": "dev", "param": "value", "moduleDir": "required"},
+				Origins: config.Origins{Params: map[string]config.Origin{
+					"environment": {Source: config.SourceCLI},
+					"param":       {Source: config.SourceParams},
+					"moduleDir":   {Source: config.SourceModuleDir},
+				}},
+				Vars:     tt.vars,
+				VarFiles: tt.varFiles,
+				Envs:     tt.cfgEnvs,
+			}
+			shell := &fakeShell{}
+			tf := NewTerraform(cfg, "testdata/modules/required", nil, true, false, shell, "terraform")
+
+			err := tf.Execute(tt.args...)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Nil(t, shell.args, "Terraform must not be run")
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTerraform_Execute_RequiredVars_AutoVarFiles(t *testing.T) {
+	moduleDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(moduleDir, "main.tf"), []byte(`variable "a" {}
+variable "b" {}
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(moduleDir, "terraform.tfvars"), []byte(`a = 1`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(moduleDir, "b.auto.tfvars.json"), []byte(`{"b": 2}`), 0644))
+
+	tf := NewTerraform(&config.Config{}, moduleDir, nil, true, false, &fakeShell{}, "terraform")
+	require.NoError(t, tf.Execute("plan"))
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr string
+	}{
+		{in: ""},
+		{in: "  -a   -b\t-c\n", want: []string{"-a", "-b", "-c"}},
+		{in: `-var 'name=a b' -var="x=\"y\""`, want: []string{"-var", "name=a b", `-var=x="y"`}},
+		{in: `-var-file=a\ b.tfvars '\n' "\n"`, want: []string{"-var-file=a b.tfvars", `\n`, `\n`}},
+		{in: `'' ""`, want: []string{"", ""}},
+		{in: `-var "name=a`, wantErr: `unterminated quote or escape in "-var \"name=a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := splitArgs(tt.in)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVarFileKeys(t *testing.T) {
+	got, err := varFileKeys("testdata/modules/required/location.tfvars")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"location", "tags", "zones"}, got)
+
+	got, err = varFileKeys("testdata/modules/required/name.tfvars.json")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name"}, got)
+
+	_, err = varFileKeys("testdata/modules/required/broken.tfvars")
+	assert.Error(t, err)
+}
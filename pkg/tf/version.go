@@ -0,0 +1,215 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// latestVersion refers to the newest release as in tfenv's '.terraform-version' files.
+const latestVersion = "latest"
+
+// VersionResolver resolves Terraform version constraints to concrete versions.
+type VersionResolver struct {
+	indexURL   string
+	cacheDir   string
+	httpClient *http.Client
+}
+
+// NewVersionResolver creates a VersionResolver which looks for matching versions
+// in cacheDir first and falls back to the release index at indexURL.
+func NewVersionResolver(indexURL string, cacheDir string) *VersionResolver {
+	return &VersionResolver{
+		indexURL:   indexURL,
+		cacheDir:   cacheDir,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// Resolve returns the newest version matching the specified constraint. An exact version
+// is returned as is. Versions already installed in the cache directory are preferred
+// over those from the release index. tfenv's 'latest' and 'latest:<regex>' are supported
+// as well and always resolved using the release index.
+func (r *VersionResolver) Resolve(constraint string) (string, error) {
+	if v, err := semver.StrictNewVersion(strings.TrimSpace(constraint)); err == nil {
+		return v.Original(), nil
+	}
+	if spec := strings.TrimSpace(constraint); spec == latestVersion || strings.HasPrefix(spec, latestVersion+":") {
+		return r.resolveLatest(spec)
+	}
+
+	constraints, err := ParseConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	cached, err := r.cachedVersions()
+	if err != nil {
+		return "", err
+	}
+	if v := newestMatching(cached, constraints); v != nil {
+		log.Println("Found cached Terraform version", v.Original(), "matching", constraint)
+		return v.Original(), nil
+	}
+
+	released, err := r.releasedVersions()
+	if err != nil {
+		return "", fmt.Errorf("could not load Terraform release index: %w", err)
+	}
+	if v := newestMatching(released, constraints); v != nil {
+		return v.Original(), nil
+	}
+	return "", fmt.Errorf("no Terraform release found matching %q", constraint)
+}
+
+// resolveLatest returns the newest stable release for 'latest' or the newest release matching
+// the regular expression for 'latest:<regex>', which may also match pre-releases.
+func (r *VersionResolver) resolveLatest(spec string) (string, error) {
+	var pattern *regexp.Regexp
+	if regex := strings.TrimPrefix(spec, latestVersion+":"); regex != spec {
+		var err error
+		if pattern, err = regexp.Compile(regex); err != nil {
+			return "", fmt.Errorf("invalid version regex in %q: %w", spec, err)
+		}
+	}
+
+	released, err := r.releasedVersions()
+	if err != nil {
+		return "", fmt.Errorf("could not load Terraform release index: %w", err)
+	}
+	sort.Sort(sort.Reverse(semver.Collection(released)))
+	for _, v := range released {
+		if pattern == nil && v.Prerelease() == "" || pattern != nil && pattern.MatchString(v.Original()) {
+			log.Println("Resolved", spec, "to Terraform version", v.Original())
+			return v.Original(), nil
+		}
+	}
+	return "", fmt.Errorf("no Terraform release found matching %q", spec)
+}
+
+func (r *VersionResolver) cachedVersions() ([]*semver.Version, error) {
+	entries, err := os.ReadDir(r.cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var versions []*semver.Version
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := semver.StrictNewVersion(e.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(r.cacheDir, e.Name(), "terraform")); err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func (r *VersionResolver) releasedVersions() ([]*semver.Version, error) {
+	resp, err := r.httpClient.Get(r.indexURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var index struct {
+		Versions map[string]json.RawMessage `json:"versions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, err
+	}
+
+	versions := make([]*semver.Version, 0, len(index.Versions))
+	for name := range index.Versions {
+		v, err := semver.StrictNewVersion(name)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func newestMatching(versions []*semver.Version, constraints *semver.Constraints) *semver.Version {
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+	for _, v := range versions {
+		if constraints.Check(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+// ParseConstraint parses a Terraform version constraint such as '~> 1.5.0' or '>= 1.4, < 1.7'.
+// The pessimistic operator '~>' follows Terraform semantics, i.e. only the rightmost
+// version component is allowed to increment.
+func ParseConstraint(constraint string) (*semver.Constraints, error) {
+	parts := strings.Split(constraint, ",")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "~>") {
+			translated, err := translatePessimistic(strings.TrimSpace(strings.TrimPrefix(part, "~>")))
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+			}
+			part = translated
+		}
+		parts[i] = part
+	}
+
+	constraints, err := semver.NewConstraint(strings.Join(parts, ", "))
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+	return constraints, nil
+}
+
+func translatePessimistic(version string) (string, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return "", err
+	}
+	segments := strings.Count(strings.SplitN(version, "-", 2)[0], ".") + 1
+	switch segments {
+	case 1:
+		return ">= " + version, nil
+	case 2:
+		return fmt.Sprintf(">= %s, < %d.0.0", version, v.Major()+1), nil
+	default:
+		return fmt.Sprintf(">= %s, < %d.%d.0", version, v.Major(), v.Minor()+1), nil
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright The gotf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionResolver_Resolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		constraint string
+		cached     []string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "exact version",
+			constraint: "1.1.5",
+			want:       "1.1.5",
+		},
+		{
+			name:       "pessimistic patch constraint",
+			constraint: "~> 1.5.0",
+			want:       "1.5.7",
+		},
+		{
+			name:       "pessimistic minor constraint",
+			constraint: "~> 1.5",
+			want:       "1.7.0",
+		},
+		{
+			name:       "range constraint",
+			constraint: ">= 1.4, < 1.7",
+			want:       "1.6.6",
+		},
+		{
+			name:       "cached version preferred",
+			constraint: ">= 1.4, < 1.7",
+			cached:     []string{"1.5.0", "1.4.6"},
+			want:       "1.5.0",
+		},
+		{
+			name:       "cached version not matching",
+			constraint: "~> 1.6.0",
+			cached:     []string{"1.5.0"},
+			want:       "1.6.6",
+		},
+		{
+			name:       "latest",
+			constraint: "latest",
+			cached:     []string{"1.5.0"},
+			want:       "1.7.0",
+		},
+		{
+			name:       "latest matching regex",
+			constraint: `latest:^1\.6`,
+			want:       "1.6.6",
+		},
+		{
+			name:       "latest pre-release matching regex",
+			constraint: "latest:beta",
+			want:       "1.6.0-beta1",
+		},
+		{
+			name:       "latest without match",
+			constraint: "latest:^2",
+			wantErr:    true,
+		},
+		{
+			name:       "latest with invalid regex",
+			constraint: "latest:[",
+			wantErr:    true,
+		},
+		{
+			name:       "no matching version",
+			constraint: ">= 2.0",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid constraint",
+			constraint: "foo",
+			wantErr:    true,
+		},
+	}
+
+	transport := &http.Transport{}
+	cwd, _ := os.Getwd()
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir(cwd)))
+	httpClient := &http.Client{Transport: transport}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, v := range tt.cached {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, v), 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, v, "terraform"), nil, 0755))
+			}
+
+			resolver := NewVersionResolver("file://./testdata/index.json", dir)
+			resolver.httpClient = httpClient
+
+			got, err := resolver.Resolve(tt.constraint)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}